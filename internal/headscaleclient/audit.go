@@ -0,0 +1,117 @@
+// Copyright (c) HashiCorp, Inc.
+
+package headscaleclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+type operationKey struct{}
+
+// Operation is terraform operation that makes headscale calls,
+// for example Resource "headscale_user" and Name "create".
+type Operation struct {
+	Resource string
+	Name     string
+}
+
+// WithOperation returns context of terraform operation, calls with this context are attributed to it.
+func WithOperation(ctx context.Context, operation Operation) context.Context {
+	return context.WithValue(ctx, operationKey{}, operation)
+}
+
+// OperationFromContext returns terraform operation of context, it is empty if context has no operation.
+func OperationFromContext(ctx context.Context) Operation {
+	operation, _ := ctx.Value(operationKey{}).(Operation)
+	return operation
+}
+
+// AuditRecord is json line of audit log.
+type AuditRecord struct {
+	Timestamp time.Time `json:"timestamp"`
+	Workspace string    `json:"workspace"`
+	// ResourceType is type of resource, data source or action that makes call, for example "headscale_user",
+	// terraform does not pass configuration address to providers.
+	ResourceType string          `json:"resource_type,omitempty"`
+	Operation    string          `json:"operation,omitempty"`
+	Method       string          `json:"method"`
+	Request      json.RawMessage `json:"request,omitempty"`
+	Response     json.RawMessage `json:"response,omitempty"`
+	Status       string          `json:"status"`
+	Error        string          `json:"error,omitempty"`
+}
+
+// NewAuditInterceptor returns interceptor that appends json line to w for every call that changes headscale.
+// Requests are redacted, secrets of responses, for example created pre auth keys and api keys,
+// are replaced by their sha256, so audit log can be matched with keys without storing them.
+func NewAuditInterceptor(w io.Writer, workspace string) grpc.UnaryClientInterceptor {
+	var mu sync.Mutex
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		if !IsMutatingMethod(method) {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		err := invoker(ctx, method, req, reply, cc, opts...)
+
+		operation := OperationFromContext(ctx)
+		record := AuditRecord{
+			Timestamp:    time.Now().UTC(),
+			Workspace:    workspace,
+			ResourceType: operation.Resource,
+			Operation:    operation.Name,
+			Method:       method,
+			Request:      rawJSON(RedactedJSON(req)),
+			Status:       status.Code(err).String(),
+		}
+		if err != nil {
+			record.Error = status.Convert(err).Message()
+		} else {
+			record.Response = rawJSON(HashedJSON(reply))
+		}
+		line, marshalErr := json.Marshal(record)
+		if marshalErr == nil {
+			mu.Lock()
+			_, marshalErr = w.Write(append(line, '\n'))
+			mu.Unlock()
+		}
+		if marshalErr != nil {
+			tflog.Error(ctx, "cant write headscale audit log", map[string]interface{}{
+				"headscale_method": method,
+				"error":            marshalErr.Error(),
+			})
+		}
+		return err
+	}
+}
+
+// HashedJSON returns json of proto message with secrets replaced by their sha256.
+func HashedJSON(v any) string {
+	return protoJSON(v, func(value any) any {
+		sum := sha256.Sum256([]byte(fmt.Sprint(value)))
+		return "sha256:" + hex.EncodeToString(sum[:])
+	})
+}
+
+func rawJSON(data string) json.RawMessage {
+	if data == "" {
+		return nil
+	}
+	return json.RawMessage(data)
+}
@@ -0,0 +1,88 @@
+// Copyright (c) HashiCorp, Inc.
+
+package headscaleclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestAuditInterceptor(t *testing.T) {
+	var output bytes.Buffer
+	interceptor := NewAuditInterceptor(&output, "prod")
+	ctx := WithOperation(context.Background(), Operation{Resource: "headscale_pre_auth_key", Name: "create"})
+	call := func(method string, req, reply proto.Message, err error) {
+		invoker := func(ctx context.Context, method string, req, r any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			if err == nil {
+				proto.Merge(r.(proto.Message), reply)
+			}
+			return err
+		}
+		_ = interceptor(ctx, method, req, reply.ProtoReflect().New().Interface(), nil, invoker)
+	}
+
+	call(
+		v1.HeadscaleService_ListPreAuthKeys_FullMethodName,
+		&v1.ListPreAuthKeysRequest{User: 1},
+		&v1.ListPreAuthKeysResponse{},
+		nil,
+	)
+	call(
+		v1.HeadscaleService_CreatePreAuthKey_FullMethodName,
+		&v1.CreatePreAuthKeyRequest{User: 1, AclTags: []string{"tag:ci"}},
+		&v1.CreatePreAuthKeyResponse{PreAuthKey: &v1.PreAuthKey{Id: 3, Key: "pre-auth-key-secret"}},
+		nil,
+	)
+	call(
+		v1.HeadscaleService_ExpirePreAuthKey_FullMethodName,
+		&v1.ExpirePreAuthKeyRequest{User: 1, Key: "expired-secret"},
+		&v1.ExpirePreAuthKeyResponse{},
+		status.Error(codes.NotFound, "record not found"),
+	)
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected records of 2 mutating calls, got: %s", output.String())
+	}
+	for _, secret := range []string{"pre-auth-key-secret", "expired-secret"} {
+		if strings.Contains(output.String(), secret) {
+			t.Errorf("secret %q is written to audit log: %s", secret, output.String())
+		}
+	}
+
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("cant decode record: %s", err)
+	}
+	expected := map[string]interface{}{
+		"workspace":     "prod",
+		"resource_type": "headscale_pre_auth_key",
+		"operation":     "create",
+		"method":        v1.HeadscaleService_CreatePreAuthKey_FullMethodName,
+		"status":        "OK",
+	}
+	for name, value := range expected {
+		if record[name] != value {
+			t.Errorf("expected %s to be %v, got %v", name, value, record[name])
+		}
+	}
+	if key := record["response"].(map[string]interface{})["preAuthKey"].(map[string]interface{})["key"]; !strings.HasPrefix(key.(string), "sha256:") {
+		t.Errorf("expected hash of created key, got %v", key)
+	}
+
+	if err := json.Unmarshal([]byte(lines[1]), &record); err != nil {
+		t.Fatalf("cant decode record: %s", err)
+	}
+	if record["status"] != "NotFound" || record["error"] != "record not found" {
+		t.Errorf("expected error of failed call, got %v", record)
+	}
+}
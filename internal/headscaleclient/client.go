@@ -0,0 +1,38 @@
+// Copyright (c) HashiCorp, Inc.
+
+package headscaleclient
+
+import (
+	"context"
+
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+)
+
+// Client is a headscale api client that does not depend on the transport
+// protocol. It is implemented over grpc and over grpc-gateway REST api.
+type Client interface {
+	CreateUser(ctx context.Context, in *v1.CreateUserRequest) (*v1.CreateUserResponse, error)
+	RenameUser(ctx context.Context, in *v1.RenameUserRequest) (*v1.RenameUserResponse, error)
+	DeleteUser(ctx context.Context, in *v1.DeleteUserRequest) (*v1.DeleteUserResponse, error)
+	ListUsers(ctx context.Context, in *v1.ListUsersRequest) (*v1.ListUsersResponse, error)
+	CreatePreAuthKey(ctx context.Context, in *v1.CreatePreAuthKeyRequest) (*v1.CreatePreAuthKeyResponse, error)
+	ExpirePreAuthKey(ctx context.Context, in *v1.ExpirePreAuthKeyRequest) (*v1.ExpirePreAuthKeyResponse, error)
+	ListPreAuthKeys(ctx context.Context, in *v1.ListPreAuthKeysRequest) (*v1.ListPreAuthKeysResponse, error)
+	DebugCreateNode(ctx context.Context, in *v1.DebugCreateNodeRequest) (*v1.DebugCreateNodeResponse, error)
+	GetNode(ctx context.Context, in *v1.GetNodeRequest) (*v1.GetNodeResponse, error)
+	SetTags(ctx context.Context, in *v1.SetTagsRequest) (*v1.SetTagsResponse, error)
+	SetApprovedRoutes(ctx context.Context, in *v1.SetApprovedRoutesRequest) (*v1.SetApprovedRoutesResponse, error)
+	RegisterNode(ctx context.Context, in *v1.RegisterNodeRequest) (*v1.RegisterNodeResponse, error)
+	DeleteNode(ctx context.Context, in *v1.DeleteNodeRequest) (*v1.DeleteNodeResponse, error)
+	ExpireNode(ctx context.Context, in *v1.ExpireNodeRequest) (*v1.ExpireNodeResponse, error)
+	RenameNode(ctx context.Context, in *v1.RenameNodeRequest) (*v1.RenameNodeResponse, error)
+	ListNodes(ctx context.Context, in *v1.ListNodesRequest) (*v1.ListNodesResponse, error)
+	MoveNode(ctx context.Context, in *v1.MoveNodeRequest) (*v1.MoveNodeResponse, error)
+	BackfillNodeIPs(ctx context.Context, in *v1.BackfillNodeIPsRequest) (*v1.BackfillNodeIPsResponse, error)
+	CreateApiKey(ctx context.Context, in *v1.CreateApiKeyRequest) (*v1.CreateApiKeyResponse, error)
+	ExpireApiKey(ctx context.Context, in *v1.ExpireApiKeyRequest) (*v1.ExpireApiKeyResponse, error)
+	ListApiKeys(ctx context.Context, in *v1.ListApiKeysRequest) (*v1.ListApiKeysResponse, error)
+	DeleteApiKey(ctx context.Context, in *v1.DeleteApiKeyRequest) (*v1.DeleteApiKeyResponse, error)
+	GetPolicy(ctx context.Context, in *v1.GetPolicyRequest) (*v1.GetPolicyResponse, error)
+	SetPolicy(ctx context.Context, in *v1.SetPolicyRequest) (*v1.SetPolicyResponse, error)
+}
@@ -0,0 +1,254 @@
+// Copyright (c) HashiCorp, Inc.
+
+package headscaleclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// ErrUnsupportedOperation is returned by adapters when headscale server does not support operation.
+var ErrUnsupportedOperation = errors.New("operation is not supported by headscale server")
+
+type RoutesAPI string
+
+const (
+	// RoutesAPIApproved is routes api of headscale v0.26+: SetApprovedRoutes and node's approved_routes.
+	RoutesAPIApproved RoutesAPI = "approved_routes"
+	// RoutesAPILegacy is routes api of headscale v0.23-v0.25: GetNodeRoutes, EnableRoute and DisableRoute.
+	RoutesAPILegacy RoutesAPI = "legacy_routes"
+	// RoutesAPIUnknown is routes api that is not supported by provider.
+	RoutesAPIUnknown RoutesAPI = "unknown"
+)
+
+type TagsAPI string
+
+const (
+	// TagsAPIForced is tags api of headscale up to v0.26: SetTags and node's forced_tags.
+	TagsAPIForced TagsAPI = "forced_tags"
+	// TagsAPIUnknown is tags api that is not supported by provider, for example new tag model of headscale.
+	TagsAPIUnknown TagsAPI = "unknown"
+)
+
+// ServerAPI describes api of headscale server that is used to choose version specific adapters.
+type ServerAPI struct {
+	// Source is human-readable origin of api description, for example "grpc reflection" or "version 0.25.1".
+	Source string
+	Routes RoutesAPI
+	Tags   TagsAPI
+}
+
+// DefaultServerAPI is api of headscale v0.26.1 which protos are used by provider.
+func DefaultServerAPI() *ServerAPI {
+	return &ServerAPI{
+		Source: "default v0.26",
+		Routes: RoutesAPIApproved,
+		Tags:   TagsAPIForced,
+	}
+}
+
+// ErrUnknownServerVersion is returned by ServerAPIFromVersion for versions of headscale that provider does not know,
+// api of such servers must be detected.
+var ErrUnknownServerVersion = errors.New("api of headscale version is not known")
+
+// serverVersionAPIs are apis of known headscale versions by minor version of v0.
+var serverVersionAPIs = map[int]ServerAPI{
+	23: {Routes: RoutesAPILegacy, Tags: TagsAPIForced},
+	24: {Routes: RoutesAPILegacy, Tags: TagsAPIForced},
+	25: {Routes: RoutesAPILegacy, Tags: TagsAPIForced},
+	26: {Routes: RoutesAPIApproved, Tags: TagsAPIForced},
+}
+
+// ServerAPIFromVersion returns api of headscale server by its version, for example "0.25.1" or "v0.26.0".
+// Versions older than v0.23 are not supported, for newer versions that provider does not know
+// it returns ErrUnknownServerVersion instead of guessing their api.
+func ServerAPIFromVersion(version string) (*ServerAPI, error) {
+	parts := strings.SplitN(strings.TrimPrefix(strings.TrimSpace(version), "v"), ".", 3)
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("version %q must have format <major>.<minor>[.<patch>]", version)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("cant parse major version of %q: %w", version, err)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("cant parse minor version of %q: %w", version, err)
+	}
+	if major == 0 && minor < 23 {
+		return nil, fmt.Errorf("headscale version %q is not supported, supported versions are v0.23 and newer", version)
+	}
+	api, ok := serverVersionAPIs[minor]
+	if major != 0 || !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownServerVersion, version)
+	}
+	api.Source = "version " + version
+	return &api, nil
+}
+
+// DetectServerAPI asks headscale server which rpc methods and node fields it supports.
+// Grpc client uses server reflection, rest client uses openapi specification of server.
+func DetectServerAPI(ctx context.Context, client Client) (*ServerAPI, error) {
+	var methods, nodeFields map[string]bool
+	var err error
+	var source string
+	switch c := client.(type) {
+	case *failoverClient:
+		return DetectServerAPI(ctx, c.activeClient())
+	case *grpcClient:
+		source = "grpc reflection"
+		methods, nodeFields, err = c.describeService(ctx)
+	case *restClient:
+		source = "openapi specification"
+		methods, nodeFields, err = c.describeService(ctx)
+	default:
+		return nil, fmt.Errorf("api detection is not supported by client %T", client)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("headscale service is not found via %s", source)
+	}
+
+	api := &ServerAPI{
+		Source: source,
+		Routes: RoutesAPIUnknown,
+		Tags:   TagsAPIUnknown,
+	}
+	switch {
+	case methods["SetApprovedRoutes"] && nodeFields["approved_routes"]:
+		api.Routes = RoutesAPIApproved
+	case methods["GetNodeRoutes"] && methods["EnableRoute"] && methods["DisableRoute"]:
+		api.Routes = RoutesAPILegacy
+	}
+	if methods["SetTags"] && nodeFields["forced_tags"] {
+		api.Tags = TagsAPIForced
+	}
+	return api, nil
+}
+
+const headscaleServiceName = "headscale.v1.HeadscaleService"
+
+func (c *grpcClient) describeService(ctx context.Context) (methods map[string]bool, nodeFields map[string]bool, err error) {
+	stream, err := grpc_reflection_v1.NewServerReflectionClient(c.conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cant open grpc reflection stream: %w", err)
+	}
+	defer func() { _ = stream.CloseSend() }()
+
+	err = stream.Send(&grpc_reflection_v1.ServerReflectionRequest{
+		MessageRequest: &grpc_reflection_v1.ServerReflectionRequest_FileContainingSymbol{
+			FileContainingSymbol: headscaleServiceName,
+		},
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("cant send grpc reflection request: %w", err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		return nil, nil, fmt.Errorf("cant receive grpc reflection response: %w", err)
+	}
+	if errResp := resp.GetErrorResponse(); errResp != nil {
+		return nil, nil, fmt.Errorf("grpc reflection error: %s", errResp.GetErrorMessage())
+	}
+
+	methods = map[string]bool{}
+	nodeFields = map[string]bool{}
+	for _, raw := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+		file := &descriptorpb.FileDescriptorProto{}
+		if err := proto.Unmarshal(raw, file); err != nil {
+			return nil, nil, fmt.Errorf("cant unmarshal file descriptor: %w", err)
+		}
+		if file.GetPackage() != "headscale.v1" {
+			continue
+		}
+		for _, service := range file.GetService() {
+			if service.GetName() != "HeadscaleService" {
+				continue
+			}
+			for _, method := range service.GetMethod() {
+				methods[method.GetName()] = true
+			}
+		}
+		for _, message := range file.GetMessageType() {
+			if message.GetName() != "Node" {
+				continue
+			}
+			for _, field := range message.GetField() {
+				nodeFields[field.GetName()] = true
+			}
+		}
+	}
+	return methods, nodeFields, nil
+}
+
+func (c *restClient) describeService(ctx context.Context) (methods map[string]bool, nodeFields map[string]bool, err error) {
+	u := c.baseURL.JoinPath("/swagger/v1/openapiv2.json")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cant create request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cant get openapi specification: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cant read openapi specification: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("cant get openapi specification, http status %d", resp.StatusCode)
+	}
+
+	spec := struct {
+		Paths map[string]map[string]struct {
+			OperationId string `json:"operationId"`
+		} `json:"paths"`
+		Definitions map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"definitions"`
+	}{}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, nil, fmt.Errorf("cant parse openapi specification: %w", err)
+	}
+
+	methods = map[string]bool{}
+	for _, operations := range spec.Paths {
+		for _, operation := range operations {
+			if name, ok := strings.CutPrefix(operation.OperationId, "HeadscaleService_"); ok {
+				methods[name] = true
+			}
+		}
+	}
+	nodeFields = map[string]bool{}
+	for name := range spec.Definitions["v1Node"].Properties {
+		nodeFields[snakeCase(name)] = true
+	}
+	return methods, nodeFields, nil
+}
+
+// snakeCase converts json name of proto field to its proto name, for example "forcedTags" to "forced_tags".
+func snakeCase(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if unicode.IsUpper(r) {
+			b.WriteByte('_')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,73 @@
+// Copyright (c) HashiCorp, Inc.
+
+package headscaleclient
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestServerAPIFromVersion(t *testing.T) {
+	testCases := []struct {
+		version     string
+		routes      RoutesAPI
+		tags        TagsAPI
+		unknown     bool
+		unsupported bool
+	}{
+		{version: "0.22.3", unsupported: true},
+		{version: "0.23.0", routes: RoutesAPILegacy, tags: TagsAPIForced},
+		{version: "v0.24.3", routes: RoutesAPILegacy, tags: TagsAPIForced},
+		{version: "0.25.1", routes: RoutesAPILegacy, tags: TagsAPIForced},
+		{version: "0.26.0", routes: RoutesAPIApproved, tags: TagsAPIForced},
+		{version: " v0.26.1 ", routes: RoutesAPIApproved, tags: TagsAPIForced},
+		{version: "0.26.0-beta.1", routes: RoutesAPIApproved, tags: TagsAPIForced},
+		{version: "0.27.0", unknown: true},
+		{version: "0.30", unknown: true},
+		{version: "1.0.0", unknown: true},
+		{version: "26", unsupported: true},
+		{version: "0.x.1", unsupported: true},
+		{version: "", unsupported: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.version, func(t *testing.T) {
+			api, err := ServerAPIFromVersion(testCase.version)
+			switch {
+			case testCase.unknown:
+				if !errors.Is(err, ErrUnknownServerVersion) {
+					t.Fatalf("expected ErrUnknownServerVersion, got api %v, error %v", api, err)
+				}
+			case testCase.unsupported:
+				if err == nil || errors.Is(err, ErrUnknownServerVersion) {
+					t.Fatalf("expected error of unsupported version, got api %v, error %v", api, err)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if api.Routes != testCase.routes || api.Tags != testCase.tags {
+					t.Errorf("expected routes %q and tags %q, got %q and %q", testCase.routes, testCase.tags, api.Routes, api.Tags)
+				}
+				if api.Source != "version "+testCase.version {
+					t.Errorf("unexpected source %q", api.Source)
+				}
+			}
+		})
+	}
+}
+
+func TestServerAPIFromVersionDoesNotShareAPI(t *testing.T) {
+	api, err := ServerAPIFromVersion("0.26.0")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	api.Routes = RoutesAPIUnknown
+	api, err = ServerAPIFromVersion("0.26.1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if api.Routes != RoutesAPIApproved {
+		t.Errorf("api of known version is changed by caller: %q", api.Routes)
+	}
+}
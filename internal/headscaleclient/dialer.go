@@ -0,0 +1,295 @@
+// Copyright (c) HashiCorp, Inc.
+
+package headscaleclient
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"golang.org/x/net/proxy"
+)
+
+// DialContextFunc dials connection to address, it has signature of net.Dialer.DialContext.
+type DialContextFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// DirectDialer returns dialer that connects to address without proxy.
+func DirectDialer() DialContextFunc {
+	return (&net.Dialer{}).DialContext
+}
+
+// NewProxyDialer returns dialer that connects to address through proxy, proxy is dialed by forward.
+// Proxy url has scheme "http" or "https" for HTTP CONNECT proxy and "socks5" or "socks5h" for SOCKS5 proxy,
+// credentials are taken from user info of url.
+func NewProxyDialer(proxyURL string, forward DialContextFunc) (DialContextFunc, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("cant parse proxy url: %w", err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy url %q has no host", u.Redacted())
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return httpConnectDialer(u, forward), nil
+	case "socks5", "socks5h":
+		var auth *proxy.Auth
+		if u.User != nil {
+			password, _ := u.User.Password()
+			auth = &proxy.Auth{User: u.User.Username(), Password: password}
+		}
+		dialer, err := proxy.SOCKS5("tcp", u.Host, auth, contextDialer(forward))
+		if err != nil {
+			return nil, fmt.Errorf("cant create socks5 dialer: %w", err)
+		}
+		//nolint:forcetypeassert
+		return dialer.(proxy.ContextDialer).DialContext, nil
+	default:
+		return nil, fmt.Errorf("proxy url %q must have scheme http, https, socks5 or socks5h", u.Redacted())
+	}
+}
+
+// contextDialer adapts DialContextFunc to dialer of golang.org/x/net/proxy.
+type contextDialer DialContextFunc
+
+func (d contextDialer) Dial(network, address string) (net.Conn, error) {
+	return d(context.Background(), network, address)
+}
+
+func (d contextDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return d(ctx, network, address)
+}
+
+func httpConnectDialer(proxyURL *url.URL, forward DialContextFunc) DialContextFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		if network != "tcp" && network != "tcp4" && network != "tcp6" {
+			return nil, fmt.Errorf("http proxy can not dial network %q", network)
+		}
+		proxyAddress := proxyURL.Host
+		if proxyURL.Port() == "" {
+			port := "80"
+			if proxyURL.Scheme == "https" {
+				port = "443"
+			}
+			proxyAddress = net.JoinHostPort(proxyURL.Hostname(), port)
+		}
+		conn, err := forward(ctx, "tcp", proxyAddress)
+		if err != nil {
+			return nil, fmt.Errorf("cant connect to proxy %s: %w", proxyAddress, err)
+		}
+		if proxyURL.Scheme == "https" {
+			tlsConn := tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname(), MinVersion: tls.VersionTLS12})
+			if err := tlsConn.HandshakeContext(ctx); err != nil {
+				_ = conn.Close()
+				return nil, fmt.Errorf("cant establish tls with proxy %s: %w", proxyAddress, err)
+			}
+			conn = tlsConn
+		}
+
+		if deadline, ok := ctx.Deadline(); ok {
+			_ = conn.SetDeadline(deadline)
+			defer func() { _ = conn.SetDeadline(time.Time{}) }()
+		}
+		req := &http.Request{
+			Method: http.MethodConnect,
+			URL:    &url.URL{Opaque: address},
+			Host:   address,
+			Header: http.Header{},
+		}
+		if proxyURL.User != nil {
+			password, _ := proxyURL.User.Password()
+			credentials := base64.StdEncoding.EncodeToString([]byte(proxyURL.User.Username() + ":" + password))
+			req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+		}
+		if err := req.Write(conn); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("cant send CONNECT to proxy %s: %w", proxyAddress, err)
+		}
+		reader := bufio.NewReader(conn)
+		resp, err := http.ReadResponse(reader, req)
+		if err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("cant read CONNECT response of proxy %s: %w", proxyAddress, err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			_ = conn.Close()
+			return nil, fmt.Errorf("proxy %s refused CONNECT to %s: %s", proxyAddress, address, resp.Status)
+		}
+		if reader.Buffered() > 0 {
+			// server can send data first, for example settings frame of http2
+			return &bufferedConn{Conn: conn, reader: reader}, nil
+		}
+		return conn, nil
+	}
+}
+
+// bufferedConn is connection with data that is already read from it into reader.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// SSHTunnelConfig is configuration of ssh connection that is used to reach headscale,
+// for example through bastion host.
+type SSHTunnelConfig struct {
+	// Host is address of ssh server, port 22 is used if it is not set.
+	Host string
+	User string
+	// PrivateKey is private key in PEM format, ssh agent from SSH_AUTH_SOCK is used if it is empty.
+	PrivateKey string
+	// KnownHosts is content of known_hosts file, "~/.ssh/known_hosts" is used if it is empty.
+	KnownHosts string
+}
+
+// NewSSHDialer returns dialer that connects to address from ssh server, ssh server is dialed by forward.
+// Both tcp addresses and unix sockets of remote host can be dialed.
+// Ssh connection is established on first dial and reused until it is closed.
+func NewSSHDialer(config SSHTunnelConfig, forward DialContextFunc) (DialContextFunc, error) {
+	host := config.Host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, "22")
+	}
+	hostKeyCallback, err := knownHostsCallback(config.KnownHosts)
+	if err != nil {
+		return nil, err
+	}
+	auth, err := sshAuth(config.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	tunnel := &sshTunnel{
+		host:    host,
+		forward: forward,
+		config: &ssh.ClientConfig{
+			User:            config.User,
+			Auth:            []ssh.AuthMethod{auth},
+			HostKeyCallback: hostKeyCallback,
+		},
+	}
+	return tunnel.dial, nil
+}
+
+func knownHostsCallback(knownHosts string) (ssh.HostKeyCallback, error) {
+	if knownHosts == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("cant find known_hosts: %w", err)
+		}
+		callback, err := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+		if err != nil {
+			return nil, fmt.Errorf("cant read known_hosts: %w", err)
+		}
+		return callback, nil
+	}
+	// knownhosts reads only files, the file is removed right after parsing.
+	file, err := os.CreateTemp("", "headscale-known-hosts-")
+	if err != nil {
+		return nil, fmt.Errorf("cant write known_hosts: %w", err)
+	}
+	defer func() { _ = os.Remove(file.Name()) }()
+	_, err = file.WriteString(knownHosts)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cant write known_hosts: %w", err)
+	}
+	callback, err := knownhosts.New(file.Name())
+	if err != nil {
+		return nil, fmt.Errorf("cant parse known_hosts: %w", err)
+	}
+	return callback, nil
+}
+
+func sshAuth(privateKey string) (ssh.AuthMethod, error) {
+	if privateKey != "" {
+		signer, err := ssh.ParsePrivateKey([]byte(privateKey))
+		if err != nil {
+			return nil, fmt.Errorf("cant parse ssh private key: %w", err)
+		}
+		return ssh.PublicKeys(signer), nil
+	}
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, errors.New("ssh private key is not set and SSH_AUTH_SOCK is empty")
+	}
+	return ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			return nil, fmt.Errorf("cant connect to ssh agent: %w", err)
+		}
+		defer conn.Close()
+		return agent.NewClient(conn).Signers()
+	}), nil
+}
+
+type sshTunnel struct {
+	host    string
+	config  *ssh.ClientConfig
+	forward DialContextFunc
+
+	mu     sync.Mutex
+	client *ssh.Client
+}
+
+func (t *sshTunnel) sshClient(ctx context.Context) (*ssh.Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.client != nil {
+		return t.client, nil
+	}
+	conn, err := t.forward(ctx, "tcp", t.host)
+	if err != nil {
+		return nil, fmt.Errorf("cant connect to ssh server %s: %w", t.host, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	sshConn, channels, requests, err := ssh.NewClientConn(conn, t.host, t.config)
+	_ = conn.SetDeadline(time.Time{})
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("cant establish ssh connection to %s: %w", t.host, err)
+	}
+	client := ssh.NewClient(sshConn, channels, requests)
+	t.client = client
+	go func() {
+		_ = client.Wait()
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.client == client {
+			t.client = nil
+		}
+	}()
+	return client, nil
+}
+
+func (t *sshTunnel) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	client, err := t.sshClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := client.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, fmt.Errorf("cant dial %s %s from ssh server %s: %w", network, address, t.host, err)
+	}
+	return conn, nil
+}
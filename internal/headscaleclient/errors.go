@@ -0,0 +1,49 @@
+// Copyright (c) HashiCorp, Inc.
+
+package headscaleclient
+
+import (
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// notFoundMessages are errors of headscale database layer that are returned with code Unknown
+// when object is missing, for example raw gorm "record not found".
+var notFoundMessages = []string{
+	"record not found",
+	"node not found",
+	"user not found",
+	"authkey not found",
+}
+
+// notFoundExceptions are errors that contain one of notFoundMessages but do not mean missing object,
+// for example registration key of RegisterNode that is unknown to headscale.
+var notFoundExceptions = []string{
+	"not found in registration cache",
+}
+
+// IsNotFound reports whether err means that headscale object does not exist:
+// grpc code NotFound or one of headscale's "not found" errors.
+func IsNotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+	st := status.Convert(err)
+	if st.Code() == codes.NotFound {
+		return true
+	}
+	message := strings.ToLower(st.Message())
+	for _, exception := range notFoundExceptions {
+		if strings.Contains(message, exception) {
+			return false
+		}
+	}
+	for _, notFound := range notFoundMessages {
+		if strings.Contains(message, notFound) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+// Copyright (c) HashiCorp, Inc.
+
+package headscaleclient
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestIsNotFound(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "nil", err: nil, expected: false},
+		{name: "grpc NotFound", err: status.Error(codes.NotFound, "no such object"), expected: true},
+		// GetNode, DeleteNode, SetTags and api key calls return raw gorm error
+		{name: "gorm record", err: status.Error(codes.Unknown, "record not found"), expected: true},
+		{name: "wrapped gorm record", err: status.Error(codes.Unknown, "updating resources using user: record not found"), expected: true},
+		// db.ErrNodeNotFound
+		{name: "node", err: status.Error(codes.Unknown, "node not found"), expected: true},
+		// db.ErrUserNotFound of GetUserByID in RenameUser, DeleteUser, ListPreAuthKeys and CreatePreAuthKey
+		{name: "user", err: status.Error(codes.Unknown, "user not found"), expected: true},
+		// db.ErrPreAuthKeyNotFound
+		{name: "pre auth key", err: status.Error(codes.Unknown, "AuthKey not found"), expected: true},
+		// grpc-gateway error of rest client
+		{name: "rest", err: status.Error(codes.Unknown, "record not found"), expected: true},
+		// db.ErrNodeNotFoundRegistrationCache of RegisterNode means unknown registration key, not missing node
+		{name: "registration cache", err: status.Error(codes.Unknown, "node not found in registration cache"), expected: false},
+		{name: "policy", err: status.Error(codes.Unknown, "acl policy not found"), expected: false},
+		{name: "invalid argument", err: status.Error(codes.InvalidArgument, "tag must start with the string 'tag:', got: server"), expected: false},
+		{name: "unauthenticated", err: status.Error(codes.Unauthenticated, "invalid token"), expected: false},
+		{name: "unavailable", err: status.Error(codes.Unavailable, "connection refused"), expected: false},
+		{name: "not grpc", err: errors.New("dial tcp: connection refused"), expected: false},
+		{name: "wrapped grpc", err: fmt.Errorf("endpoint a: %w", status.Error(codes.NotFound, "gone")), expected: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if actual := IsNotFound(testCase.err); actual != testCase.expected {
+				t.Errorf("expected %t for %v, got %t", testCase.expected, testCase.err, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,368 @@
+// Copyright (c) HashiCorp, Inc.
+
+package headscaleclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Endpoint is client of one headscale endpoint in high-availability setup.
+type Endpoint struct {
+	// Name is endpoint as it is configured, it is used in errors and logs.
+	Name   string
+	Client Client
+}
+
+type failoverClient struct {
+	endpoints []Endpoint
+
+	mu     sync.Mutex
+	active int
+}
+
+// NewFailoverClient returns Client that sends calls to active endpoint and switches
+// to the next endpoint in order when active one is unavailable.
+// Calls that change headscale switch endpoint only if clients of endpoints use NewConnectionInterceptor
+// and call was not sent.
+// The first endpoint is active until ProbeEndpoints or a failed call picks another one.
+// Errors of calls are prefixed with the name of endpoint that returned them.
+func NewFailoverClient(endpoints []Endpoint) (Client, error) {
+	if len(endpoints) == 0 {
+		return nil, errors.New("at least one endpoint is required")
+	}
+	return &failoverClient{endpoints: endpoints}, nil
+}
+
+// ProbeEndpoints checks endpoints of failover client in order and makes the first healthy one active.
+// Endpoint is healthy if it answers, even with error, for example with Unauthenticated.
+// It returns errors of endpoints that are not healthy, client without failover is not probed.
+func ProbeEndpoints(ctx context.Context, client Client, timeout time.Duration) []error {
+	c, ok := client.(*failoverClient)
+	if !ok {
+		return nil
+	}
+	var probeErrors []error
+	for i, endpoint := range c.endpoints {
+		probeCtx, cancel := context.WithTimeout(ctx, timeout)
+		_, err := endpoint.Client.ListApiKeys(probeCtx, &v1.ListApiKeysRequest{})
+		cancel()
+		if !isEndpointFailure(v1.HeadscaleService_ListApiKeys_FullMethodName, err) && status.Code(err) != codes.DeadlineExceeded {
+			c.setActive(i)
+			return probeErrors
+		}
+		probeErrors = append(probeErrors, endpointError(endpoint, err))
+	}
+	return probeErrors
+}
+
+// isEndpointFailure reports whether call can be retried on another endpoint:
+// endpoint is not reachable, so request was not handled by it.
+// Call that changes headscale is retried only if it was not sent, see NewConnectionInterceptor,
+// endpoint could apply it before connection was broken, and retry would repeat it, for example create user twice.
+func isEndpointFailure(method string, err error) bool {
+	if status.Code(err) != codes.Unavailable {
+		return false
+	}
+	return !IsMutatingMethod(method) || errors.Is(err, errNotSent)
+}
+
+// errNotSent marks errors of calls that did not reach endpoint.
+var errNotSent = errors.New("call is not sent")
+
+// notSentError is error of call that was not sent, because connection to endpoint was not established.
+// It keeps grpc status of original error.
+type notSentError struct {
+	err error
+}
+
+func (e *notSentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *notSentError) GRPCStatus() *status.Status {
+	return status.Convert(e.err)
+}
+
+func (e *notSentError) Unwrap() error {
+	return e.err
+}
+
+func (e *notSentError) Is(target error) bool {
+	return target == errNotSent
+}
+
+// NewConnectionInterceptor returns interceptor that marks "Unavailable" errors of calls
+// that were not sent, because connection to endpoint was not established,
+// so failover client retries them on another endpoint even if they change headscale.
+// Call is sent if it got connection to peer, rest client reports peer of connection like grpc.
+func NewConnectionInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		var p peer.Peer
+		err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Peer(&p))...)
+		if status.Code(err) == codes.Unavailable && p.Addr == nil {
+			return &notSentError{err: err}
+		}
+		return err
+	}
+}
+
+// endpointError adds endpoint name to error and keeps grpc status code or wrapped error.
+func endpointError(endpoint Endpoint, err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return fmt.Errorf("endpoint %q: %w", endpoint.Name, err)
+	}
+	return status.Errorf(st.Code(), "endpoint %q: %s", endpoint.Name, st.Message())
+}
+
+func (c *failoverClient) activeIndex() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.active
+}
+
+func (c *failoverClient) setActive(i int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.active = i
+}
+
+// activeClient returns client of active endpoint, it is used for api detection.
+func (c *failoverClient) activeClient() Client {
+	return c.endpoints[c.activeIndex()].Client
+}
+
+// failover calls active endpoint, and the other endpoints in order if it is unavailable, see isEndpointFailure.
+// Endpoint that answers becomes active for next calls.
+func failover[T any](ctx context.Context, c *failoverClient, method string, call func(ctx context.Context, client Client) (T, error)) (T, error) {
+	active := c.activeIndex()
+	order := []int{active}
+	for i := range c.endpoints {
+		if i != active {
+			order = append(order, i)
+		}
+	}
+
+	var result T
+	var failures []string
+	for _, i := range order {
+		endpoint := c.endpoints[i]
+		if ctx.Err() != nil {
+			break
+		}
+		endpointCtx := tflog.SetField(ctx, "headscale_endpoint", endpoint.Name)
+		response, err := call(endpointCtx, endpoint.Client)
+		if !isEndpointFailure(method, err) || ctx.Err() != nil {
+			if i != active {
+				tflog.Warn(endpointCtx, "headscale endpoint failover", map[string]interface{}{
+					"headscale_failed_endpoints": failures,
+				})
+				c.setActive(i)
+			}
+			if err != nil {
+				return result, endpointError(endpoint, err)
+			}
+			return response, nil
+		}
+		failures = append(failures, status.Convert(endpointError(endpoint, err)).Message())
+	}
+	if ctx.Err() != nil && len(failures) == 0 {
+		return result, status.FromContextError(ctx.Err()).Err()
+	}
+	return result, status.Errorf(codes.Unavailable, "all headscale endpoints failed: %s", strings.Join(failures, "; "))
+}
+
+func (c *failoverClient) CreateUser(ctx context.Context, in *v1.CreateUserRequest) (*v1.CreateUserResponse, error) {
+	return failover(ctx, c, v1.HeadscaleService_CreateUser_FullMethodName, func(ctx context.Context, client Client) (*v1.CreateUserResponse, error) {
+		return client.CreateUser(ctx, in)
+	})
+}
+
+func (c *failoverClient) RenameUser(ctx context.Context, in *v1.RenameUserRequest) (*v1.RenameUserResponse, error) {
+	return failover(ctx, c, v1.HeadscaleService_RenameUser_FullMethodName, func(ctx context.Context, client Client) (*v1.RenameUserResponse, error) {
+		return client.RenameUser(ctx, in)
+	})
+}
+
+func (c *failoverClient) DeleteUser(ctx context.Context, in *v1.DeleteUserRequest) (*v1.DeleteUserResponse, error) {
+	return failover(ctx, c, v1.HeadscaleService_DeleteUser_FullMethodName, func(ctx context.Context, client Client) (*v1.DeleteUserResponse, error) {
+		return client.DeleteUser(ctx, in)
+	})
+}
+
+func (c *failoverClient) ListUsers(ctx context.Context, in *v1.ListUsersRequest) (*v1.ListUsersResponse, error) {
+	return failover(ctx, c, v1.HeadscaleService_ListUsers_FullMethodName, func(ctx context.Context, client Client) (*v1.ListUsersResponse, error) {
+		return client.ListUsers(ctx, in)
+	})
+}
+
+func (c *failoverClient) CreatePreAuthKey(ctx context.Context, in *v1.CreatePreAuthKeyRequest) (*v1.CreatePreAuthKeyResponse, error) {
+	return failover(ctx, c, v1.HeadscaleService_CreatePreAuthKey_FullMethodName, func(ctx context.Context, client Client) (*v1.CreatePreAuthKeyResponse, error) {
+		return client.CreatePreAuthKey(ctx, in)
+	})
+}
+
+func (c *failoverClient) ExpirePreAuthKey(ctx context.Context, in *v1.ExpirePreAuthKeyRequest) (*v1.ExpirePreAuthKeyResponse, error) {
+	return failover(ctx, c, v1.HeadscaleService_ExpirePreAuthKey_FullMethodName, func(ctx context.Context, client Client) (*v1.ExpirePreAuthKeyResponse, error) {
+		return client.ExpirePreAuthKey(ctx, in)
+	})
+}
+
+func (c *failoverClient) ListPreAuthKeys(ctx context.Context, in *v1.ListPreAuthKeysRequest) (*v1.ListPreAuthKeysResponse, error) {
+	return failover(ctx, c, v1.HeadscaleService_ListPreAuthKeys_FullMethodName, func(ctx context.Context, client Client) (*v1.ListPreAuthKeysResponse, error) {
+		return client.ListPreAuthKeys(ctx, in)
+	})
+}
+
+func (c *failoverClient) DebugCreateNode(ctx context.Context, in *v1.DebugCreateNodeRequest) (*v1.DebugCreateNodeResponse, error) {
+	return failover(ctx, c, v1.HeadscaleService_DebugCreateNode_FullMethodName, func(ctx context.Context, client Client) (*v1.DebugCreateNodeResponse, error) {
+		return client.DebugCreateNode(ctx, in)
+	})
+}
+
+func (c *failoverClient) GetNode(ctx context.Context, in *v1.GetNodeRequest) (*v1.GetNodeResponse, error) {
+	return failover(ctx, c, v1.HeadscaleService_GetNode_FullMethodName, func(ctx context.Context, client Client) (*v1.GetNodeResponse, error) {
+		return client.GetNode(ctx, in)
+	})
+}
+
+func (c *failoverClient) SetTags(ctx context.Context, in *v1.SetTagsRequest) (*v1.SetTagsResponse, error) {
+	return failover(ctx, c, v1.HeadscaleService_SetTags_FullMethodName, func(ctx context.Context, client Client) (*v1.SetTagsResponse, error) {
+		return client.SetTags(ctx, in)
+	})
+}
+
+func (c *failoverClient) SetApprovedRoutes(ctx context.Context, in *v1.SetApprovedRoutesRequest) (*v1.SetApprovedRoutesResponse, error) {
+	return failover(ctx, c, v1.HeadscaleService_SetApprovedRoutes_FullMethodName, func(ctx context.Context, client Client) (*v1.SetApprovedRoutesResponse, error) {
+		return client.SetApprovedRoutes(ctx, in)
+	})
+}
+
+func (c *failoverClient) RegisterNode(ctx context.Context, in *v1.RegisterNodeRequest) (*v1.RegisterNodeResponse, error) {
+	return failover(ctx, c, v1.HeadscaleService_RegisterNode_FullMethodName, func(ctx context.Context, client Client) (*v1.RegisterNodeResponse, error) {
+		return client.RegisterNode(ctx, in)
+	})
+}
+
+func (c *failoverClient) DeleteNode(ctx context.Context, in *v1.DeleteNodeRequest) (*v1.DeleteNodeResponse, error) {
+	return failover(ctx, c, v1.HeadscaleService_DeleteNode_FullMethodName, func(ctx context.Context, client Client) (*v1.DeleteNodeResponse, error) {
+		return client.DeleteNode(ctx, in)
+	})
+}
+
+func (c *failoverClient) ExpireNode(ctx context.Context, in *v1.ExpireNodeRequest) (*v1.ExpireNodeResponse, error) {
+	return failover(ctx, c, v1.HeadscaleService_ExpireNode_FullMethodName, func(ctx context.Context, client Client) (*v1.ExpireNodeResponse, error) {
+		return client.ExpireNode(ctx, in)
+	})
+}
+
+func (c *failoverClient) RenameNode(ctx context.Context, in *v1.RenameNodeRequest) (*v1.RenameNodeResponse, error) {
+	return failover(ctx, c, v1.HeadscaleService_RenameNode_FullMethodName, func(ctx context.Context, client Client) (*v1.RenameNodeResponse, error) {
+		return client.RenameNode(ctx, in)
+	})
+}
+
+func (c *failoverClient) ListNodes(ctx context.Context, in *v1.ListNodesRequest) (*v1.ListNodesResponse, error) {
+	return failover(ctx, c, v1.HeadscaleService_ListNodes_FullMethodName, func(ctx context.Context, client Client) (*v1.ListNodesResponse, error) {
+		return client.ListNodes(ctx, in)
+	})
+}
+
+func (c *failoverClient) MoveNode(ctx context.Context, in *v1.MoveNodeRequest) (*v1.MoveNodeResponse, error) {
+	return failover(ctx, c, v1.HeadscaleService_MoveNode_FullMethodName, func(ctx context.Context, client Client) (*v1.MoveNodeResponse, error) {
+		return client.MoveNode(ctx, in)
+	})
+}
+
+func (c *failoverClient) BackfillNodeIPs(ctx context.Context, in *v1.BackfillNodeIPsRequest) (*v1.BackfillNodeIPsResponse, error) {
+	return failover(ctx, c, v1.HeadscaleService_BackfillNodeIPs_FullMethodName, func(ctx context.Context, client Client) (*v1.BackfillNodeIPsResponse, error) {
+		return client.BackfillNodeIPs(ctx, in)
+	})
+}
+
+func (c *failoverClient) CreateApiKey(ctx context.Context, in *v1.CreateApiKeyRequest) (*v1.CreateApiKeyResponse, error) {
+	return failover(ctx, c, v1.HeadscaleService_CreateApiKey_FullMethodName, func(ctx context.Context, client Client) (*v1.CreateApiKeyResponse, error) {
+		return client.CreateApiKey(ctx, in)
+	})
+}
+
+func (c *failoverClient) ExpireApiKey(ctx context.Context, in *v1.ExpireApiKeyRequest) (*v1.ExpireApiKeyResponse, error) {
+	return failover(ctx, c, v1.HeadscaleService_ExpireApiKey_FullMethodName, func(ctx context.Context, client Client) (*v1.ExpireApiKeyResponse, error) {
+		return client.ExpireApiKey(ctx, in)
+	})
+}
+
+func (c *failoverClient) ListApiKeys(ctx context.Context, in *v1.ListApiKeysRequest) (*v1.ListApiKeysResponse, error) {
+	return failover(ctx, c, v1.HeadscaleService_ListApiKeys_FullMethodName, func(ctx context.Context, client Client) (*v1.ListApiKeysResponse, error) {
+		return client.ListApiKeys(ctx, in)
+	})
+}
+
+func (c *failoverClient) DeleteApiKey(ctx context.Context, in *v1.DeleteApiKeyRequest) (*v1.DeleteApiKeyResponse, error) {
+	return failover(ctx, c, v1.HeadscaleService_DeleteApiKey_FullMethodName, func(ctx context.Context, client Client) (*v1.DeleteApiKeyResponse, error) {
+		return client.DeleteApiKey(ctx, in)
+	})
+}
+
+func (c *failoverClient) GetPolicy(ctx context.Context, in *v1.GetPolicyRequest) (*v1.GetPolicyResponse, error) {
+	return failover(ctx, c, v1.HeadscaleService_GetPolicy_FullMethodName, func(ctx context.Context, client Client) (*v1.GetPolicyResponse, error) {
+		return client.GetPolicy(ctx, in)
+	})
+}
+
+func (c *failoverClient) SetPolicy(ctx context.Context, in *v1.SetPolicyRequest) (*v1.SetPolicyResponse, error) {
+	return failover(ctx, c, v1.HeadscaleService_SetPolicy_FullMethodName, func(ctx context.Context, client Client) (*v1.SetPolicyResponse, error) {
+		return client.SetPolicy(ctx, in)
+	})
+}
+
+// legacyRoutes calls legacy routes api of endpoint, all endpoints use the same protocol.
+func legacyRoutes[T any](ctx context.Context, c *failoverClient, method string, call func(ctx context.Context, client legacyRoutesClient) (T, error)) (T, error) {
+	return failover(ctx, c, method, func(ctx context.Context, client Client) (T, error) {
+		legacy, ok := client.(legacyRoutesClient)
+		if !ok {
+			var result T
+			return result, fmt.Errorf("%w: legacy routes api is not supported by client %T", ErrUnsupportedOperation, client)
+		}
+		return call(ctx, legacy)
+	})
+}
+
+func (c *failoverClient) getNodeRoutes(ctx context.Context, nodeId uint64) ([]legacyRoute, error) {
+	return legacyRoutes(ctx, c, legacyGetNodeRoutesMethod, func(ctx context.Context, client legacyRoutesClient) ([]legacyRoute, error) {
+		return client.getNodeRoutes(ctx, nodeId)
+	})
+}
+
+func (c *failoverClient) enableRoute(ctx context.Context, routeId uint64) error {
+	_, err := legacyRoutes(ctx, c, legacyEnableRouteMethod, func(ctx context.Context, client legacyRoutesClient) (struct{}, error) {
+		return struct{}{}, client.enableRoute(ctx, routeId)
+	})
+	return err
+}
+
+func (c *failoverClient) disableRoute(ctx context.Context, routeId uint64) error {
+	_, err := legacyRoutes(ctx, c, legacyDisableRouteMethod, func(ctx context.Context, client legacyRoutesClient) (struct{}, error) {
+		return struct{}{}, client.disableRoute(ctx, routeId)
+	})
+	return err
+}
@@ -0,0 +1,270 @@
+// Copyright (c) HashiCorp, Inc.
+
+package headscaleclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// fakeEndpoint is client of endpoint that answers every call with err and records names of called endpoints.
+type fakeEndpoint struct {
+	Client
+
+	name  string
+	err   error
+	calls *[]string
+}
+
+func (e *fakeEndpoint) ListUsers(ctx context.Context, in *v1.ListUsersRequest) (*v1.ListUsersResponse, error) {
+	*e.calls = append(*e.calls, e.name)
+	if e.err != nil {
+		return nil, e.err
+	}
+	return &v1.ListUsersResponse{}, nil
+}
+
+func (e *fakeEndpoint) CreateUser(ctx context.Context, in *v1.CreateUserRequest) (*v1.CreateUserResponse, error) {
+	*e.calls = append(*e.calls, e.name)
+	if e.err != nil {
+		return nil, e.err
+	}
+	return &v1.CreateUserResponse{}, nil
+}
+
+func newFakeFailover(t *testing.T, errs map[string]error, names ...string) (*failoverClient, map[string]*fakeEndpoint, *[]string) {
+	t.Helper()
+	var calls []string
+	fakes := make(map[string]*fakeEndpoint, len(names))
+	endpoints := make([]Endpoint, 0, len(names))
+	for _, name := range names {
+		fakes[name] = &fakeEndpoint{name: name, err: errs[name], calls: &calls}
+		endpoints = append(endpoints, Endpoint{Name: name, Client: fakes[name]})
+	}
+	client, err := NewFailoverClient(endpoints)
+	if err != nil {
+		t.Fatalf("cant create failover client: %s", err)
+	}
+	return client.(*failoverClient), fakes, &calls
+}
+
+func TestFailoverEndpointOrder(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	client, fakes, calls := newFakeFailover(t, map[string]error{"a": unavailable, "b": unavailable}, "a", "b", "c")
+	ctx := context.Background()
+
+	if _, err := client.ListUsers(ctx, &v1.ListUsersRequest{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if actual := strings.Join(*calls, ","); actual != "a,b,c" {
+		t.Errorf("expected endpoints to be called in order a,b,c, got %s", actual)
+	}
+	if client.activeIndex() != 2 {
+		t.Errorf("expected answered endpoint c to be active, got %d", client.activeIndex())
+	}
+
+	// active endpoint is called first, the others keep configured order
+	*calls = nil
+	fakes["a"].err = nil
+	fakes["c"].err = unavailable
+	if _, err := client.ListUsers(ctx, &v1.ListUsersRequest{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if actual := strings.Join(*calls, ","); actual != "c,a" {
+		t.Errorf("expected endpoints to be called in order c,a, got %s", actual)
+	}
+	if client.activeIndex() != 0 {
+		t.Errorf("expected answered endpoint a to be active, got %d", client.activeIndex())
+	}
+
+	*calls = nil
+	if _, err := client.ListUsers(ctx, &v1.ListUsersRequest{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if actual := strings.Join(*calls, ","); actual != "a" {
+		t.Errorf("expected only active endpoint a to be called, got %s", actual)
+	}
+}
+
+func TestFailoverErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		errs     map[string]error
+		code     codes.Code
+		message  string
+		wrapped  error
+		expected string
+	}{
+		{
+			name:     "error of answered endpoint",
+			errs:     map[string]error{"a": status.Error(codes.Unavailable, "connection refused"), "b": status.Error(codes.NotFound, "user not found")},
+			code:     codes.NotFound,
+			message:  `endpoint "b": user not found`,
+			expected: "a,b",
+		},
+		{
+			name: "all endpoints failed",
+			errs: map[string]error{"a": status.Error(codes.Unavailable, "connection refused"), "b": status.Error(codes.Unavailable, "no route to host")},
+			code: codes.Unavailable,
+			message: `all headscale endpoints failed: endpoint "a": connection refused; ` +
+				`endpoint "b": no route to host`,
+			expected: "a,b",
+		},
+		{
+			name:     "error without status",
+			errs:     map[string]error{"a": ErrUnsupportedOperation},
+			code:     codes.Unknown,
+			message:  `endpoint "a": ` + ErrUnsupportedOperation.Error(),
+			wrapped:  ErrUnsupportedOperation,
+			expected: "a",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client, _, calls := newFakeFailover(t, testCase.errs, "a", "b")
+			_, err := client.ListUsers(context.Background(), &v1.ListUsersRequest{})
+			if status.Code(err) != testCase.code || status.Convert(err).Message() != testCase.message {
+				t.Errorf("expected %s error %q, got %v", testCase.code, testCase.message, err)
+			}
+			if testCase.wrapped != nil && !errors.Is(err, testCase.wrapped) {
+				t.Errorf("expected error to wrap %v, got %v", testCase.wrapped, err)
+			}
+			if actual := strings.Join(*calls, ","); actual != testCase.expected {
+				t.Errorf("expected calls of %s, got %s", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestFailoverOfMutatingCalls(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{
+			name:     "sent call is not repeated",
+			err:      status.Error(codes.Unavailable, "error reading from server: EOF"),
+			expected: "a",
+		},
+		{
+			name:     "not sent call is retried",
+			err:      &notSentError{err: status.Error(codes.Unavailable, "connection refused")},
+			expected: "a,b",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client, _, calls := newFakeFailover(t, map[string]error{"a": testCase.err}, "a", "b")
+			_, err := client.CreateUser(context.Background(), &v1.CreateUserRequest{Name: "alice"})
+			if actual := strings.Join(*calls, ","); actual != testCase.expected {
+				t.Errorf("expected calls of %s, got %s", testCase.expected, actual)
+			}
+			if testCase.expected == "a" && (status.Code(err) != codes.Unavailable || !strings.HasPrefix(status.Convert(err).Message(), `endpoint "a": `)) {
+				t.Errorf("expected unavailable error of endpoint a, got %v", err)
+			}
+		})
+	}
+}
+
+func TestConnectionInterceptor(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	testCases := []struct {
+		name    string
+		err     error
+		sent    bool
+		notSent bool
+	}{
+		{name: "unavailable without connection", err: unavailable, notSent: true},
+		{name: "unavailable after connection", err: unavailable, sent: true},
+		{name: "other error without connection", err: status.Error(codes.Internal, "internal")},
+		{name: "success", sent: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				for _, opt := range opts {
+					if p, ok := opt.(grpc.PeerCallOption); ok && testCase.sent {
+						p.PeerAddr.Addr = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 50443}
+					}
+				}
+				return testCase.err
+			}
+			err := NewConnectionInterceptor()(context.Background(), v1.HeadscaleService_CreateUser_FullMethodName, nil, nil, nil, invoker)
+			if errors.Is(err, errNotSent) != testCase.notSent {
+				t.Errorf("expected not sent %t, got error %v", testCase.notSent, err)
+			}
+			if status.Code(err) != status.Code(testCase.err) || status.Convert(err).Message() != status.Convert(testCase.err).Message() {
+				t.Errorf("expected status of %v, got %v", testCase.err, err)
+			}
+		})
+	}
+}
+
+// closedAddress returns address where nothing listens.
+func closedAddress(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cant listen: %s", err)
+	}
+	address := listener.Addr().String()
+	_ = listener.Close()
+	return address
+}
+
+func TestConnectionInterceptorOfClients(t *testing.T) {
+	conn, err := grpc.NewClient(
+		closedAddress(t),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(NewConnectionInterceptor()),
+	)
+	if err != nil {
+		t.Fatalf("cant create grpc client: %s", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	_, err = NewGRPCClient(conn).CreateUser(context.Background(), &v1.CreateUserRequest{Name: "alice"})
+	if status.Code(err) != codes.Unavailable || !errors.Is(err, errNotSent) {
+		t.Errorf("expected not sent grpc call, got %v", err)
+	}
+
+	restClient, err := NewRESTClient("http://"+closedAddress(t), nil, "", NewConnectionInterceptor())
+	if err != nil {
+		t.Fatalf("cant create rest client: %s", err)
+	}
+	_, err = restClient.CreateUser(context.Background(), &v1.CreateUserRequest{Name: "alice"})
+	if status.Code(err) != codes.Unavailable || !errors.Is(err, errNotSent) {
+		t.Errorf("expected not sent rest call, got %v", err)
+	}
+
+	// server gets request and drops connection without response, request could be applied
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			_ = conn.Close()
+		}
+	}))
+	t.Cleanup(server.Close)
+	restClient, err = NewRESTClient(server.URL, server.Client(), "", NewConnectionInterceptor())
+	if err != nil {
+		t.Fatalf("cant create rest client: %s", err)
+	}
+	_, err = restClient.CreateUser(context.Background(), &v1.CreateUserRequest{Name: "alice"})
+	if status.Code(err) != codes.Unavailable || errors.Is(err, errNotSent) {
+		t.Errorf("expected sent rest call, got %v", err)
+	}
+}
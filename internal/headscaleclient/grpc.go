@@ -5,15 +5,126 @@ package headscaleclient
 import (
 	"context"
 
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 )
 
+type grpcClient struct {
+	conn   grpc.ClientConnInterface
+	client v1.HeadscaleServiceClient
+}
+
+// NewGRPCClient returns Client that calls headscale over grpc connection.
+func NewGRPCClient(conn grpc.ClientConnInterface) Client {
+	return &grpcClient{conn: conn, client: v1.NewHeadscaleServiceClient(conn)}
+}
+
+func (c *grpcClient) CreateUser(ctx context.Context, in *v1.CreateUserRequest) (*v1.CreateUserResponse, error) {
+	return c.client.CreateUser(ctx, in)
+}
+
+func (c *grpcClient) RenameUser(ctx context.Context, in *v1.RenameUserRequest) (*v1.RenameUserResponse, error) {
+	return c.client.RenameUser(ctx, in)
+}
+
+func (c *grpcClient) DeleteUser(ctx context.Context, in *v1.DeleteUserRequest) (*v1.DeleteUserResponse, error) {
+	return c.client.DeleteUser(ctx, in)
+}
+
+func (c *grpcClient) ListUsers(ctx context.Context, in *v1.ListUsersRequest) (*v1.ListUsersResponse, error) {
+	return c.client.ListUsers(ctx, in)
+}
+
+func (c *grpcClient) CreatePreAuthKey(ctx context.Context, in *v1.CreatePreAuthKeyRequest) (*v1.CreatePreAuthKeyResponse, error) {
+	return c.client.CreatePreAuthKey(ctx, in)
+}
+
+func (c *grpcClient) ExpirePreAuthKey(ctx context.Context, in *v1.ExpirePreAuthKeyRequest) (*v1.ExpirePreAuthKeyResponse, error) {
+	return c.client.ExpirePreAuthKey(ctx, in)
+}
+
+func (c *grpcClient) ListPreAuthKeys(ctx context.Context, in *v1.ListPreAuthKeysRequest) (*v1.ListPreAuthKeysResponse, error) {
+	return c.client.ListPreAuthKeys(ctx, in)
+}
+
+func (c *grpcClient) DebugCreateNode(ctx context.Context, in *v1.DebugCreateNodeRequest) (*v1.DebugCreateNodeResponse, error) {
+	return c.client.DebugCreateNode(ctx, in)
+}
+
+func (c *grpcClient) GetNode(ctx context.Context, in *v1.GetNodeRequest) (*v1.GetNodeResponse, error) {
+	return c.client.GetNode(ctx, in)
+}
+
+func (c *grpcClient) SetTags(ctx context.Context, in *v1.SetTagsRequest) (*v1.SetTagsResponse, error) {
+	return c.client.SetTags(ctx, in)
+}
+
+func (c *grpcClient) SetApprovedRoutes(ctx context.Context, in *v1.SetApprovedRoutesRequest) (*v1.SetApprovedRoutesResponse, error) {
+	return c.client.SetApprovedRoutes(ctx, in)
+}
+
+func (c *grpcClient) RegisterNode(ctx context.Context, in *v1.RegisterNodeRequest) (*v1.RegisterNodeResponse, error) {
+	return c.client.RegisterNode(ctx, in)
+}
+
+func (c *grpcClient) DeleteNode(ctx context.Context, in *v1.DeleteNodeRequest) (*v1.DeleteNodeResponse, error) {
+	return c.client.DeleteNode(ctx, in)
+}
+
+func (c *grpcClient) ExpireNode(ctx context.Context, in *v1.ExpireNodeRequest) (*v1.ExpireNodeResponse, error) {
+	return c.client.ExpireNode(ctx, in)
+}
+
+func (c *grpcClient) RenameNode(ctx context.Context, in *v1.RenameNodeRequest) (*v1.RenameNodeResponse, error) {
+	return c.client.RenameNode(ctx, in)
+}
+
+func (c *grpcClient) ListNodes(ctx context.Context, in *v1.ListNodesRequest) (*v1.ListNodesResponse, error) {
+	return c.client.ListNodes(ctx, in)
+}
+
+func (c *grpcClient) MoveNode(ctx context.Context, in *v1.MoveNodeRequest) (*v1.MoveNodeResponse, error) {
+	return c.client.MoveNode(ctx, in)
+}
+
+func (c *grpcClient) BackfillNodeIPs(ctx context.Context, in *v1.BackfillNodeIPsRequest) (*v1.BackfillNodeIPsResponse, error) {
+	return c.client.BackfillNodeIPs(ctx, in)
+}
+
+func (c *grpcClient) CreateApiKey(ctx context.Context, in *v1.CreateApiKeyRequest) (*v1.CreateApiKeyResponse, error) {
+	return c.client.CreateApiKey(ctx, in)
+}
+
+func (c *grpcClient) ExpireApiKey(ctx context.Context, in *v1.ExpireApiKeyRequest) (*v1.ExpireApiKeyResponse, error) {
+	return c.client.ExpireApiKey(ctx, in)
+}
+
+func (c *grpcClient) ListApiKeys(ctx context.Context, in *v1.ListApiKeysRequest) (*v1.ListApiKeysResponse, error) {
+	return c.client.ListApiKeys(ctx, in)
+}
+
+func (c *grpcClient) DeleteApiKey(ctx context.Context, in *v1.DeleteApiKeyRequest) (*v1.DeleteApiKeyResponse, error) {
+	return c.client.DeleteApiKey(ctx, in)
+}
+
+func (c *grpcClient) GetPolicy(ctx context.Context, in *v1.GetPolicyRequest) (*v1.GetPolicyResponse, error) {
+	return c.client.GetPolicy(ctx, in)
+}
+
+func (c *grpcClient) SetPolicy(ctx context.Context, in *v1.SetPolicyRequest) (*v1.SetPolicyResponse, error) {
+	return c.client.SetPolicy(ctx, in)
+}
+
 type tokenAuth struct {
-	token string
+	token                    string
+	requireTransportSecurity bool
 }
 
-func NewGRPCTokenAuth(token string) credentials.PerRPCCredentials {
-	return &tokenAuth{token: token}
+// NewGRPCTokenAuth returns bearer token credentials. requireTransportSecurity
+// should be disabled only for plaintext and unix socket connections.
+func NewGRPCTokenAuth(token string, requireTransportSecurity bool) credentials.PerRPCCredentials {
+	return &tokenAuth{token: token, requireTransportSecurity: requireTransportSecurity}
 }
 
 // Return value is mapped to request headers.
@@ -26,6 +137,6 @@ func (t *tokenAuth) GetRequestMetadata(
 	}, nil
 }
 
-func (*tokenAuth) RequireTransportSecurity() bool {
-	return true
+func (t *tokenAuth) RequireTransportSecurity() bool {
+	return t.requireTransportSecurity
 }
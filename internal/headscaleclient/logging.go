@@ -0,0 +1,148 @@
+// Copyright (c) HashiCorp, Inc.
+
+package headscaleclient
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	requestIdHeader = "x-request-id"
+	redactedValue   = "***"
+)
+
+// secretFields are json names of message fields that must not be logged:
+// pre auth keys, api keys and node keys.
+var secretFields = map[string]bool{
+	"key":        true,
+	"apiKey":     true,
+	"nodeKey":    true,
+	"machineKey": true,
+	"discoKey":   true,
+}
+
+// NewLoggingInterceptor returns interceptor that logs every headscale call via tflog:
+// method, duration, status code and request ids at DEBUG level,
+// redacted request and response bodies at TRACE level.
+func NewLoggingInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		requestId := newRequestId()
+		ctx = metadata.AppendToOutgoingContext(ctx, requestIdHeader, requestId)
+		ctx = tflog.SetField(ctx, "headscale_method", method)
+		ctx = tflog.SetField(ctx, "headscale_request_id", requestId)
+
+		tflog.Trace(ctx, "headscale request", map[string]interface{}{
+			"headscale_request": RedactedJSON(req),
+		})
+
+		var header metadata.MD
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Header(&header))...)
+		fields := map[string]interface{}{
+			"headscale_duration_ms": time.Since(start).Milliseconds(),
+			"headscale_status_code": status.Code(err).String(),
+		}
+		if serverRequestIds := header.Get(requestIdHeader); len(serverRequestIds) > 0 {
+			fields["headscale_server_request_id"] = serverRequestIds[0]
+		}
+		if err != nil {
+			fields["headscale_error"] = status.Convert(err).Message()
+			tflog.Debug(ctx, "headscale call failed", fields)
+			return err
+		}
+		tflog.Debug(ctx, "headscale call", fields)
+		tflog.Trace(ctx, "headscale response", map[string]interface{}{
+			"headscale_response": RedactedJSON(reply),
+		})
+		return nil
+	}
+}
+
+// RedactedJSON returns json of proto message with masked secrets.
+func RedactedJSON(v any) string {
+	return protoJSON(v, func(any) any { return redactedValue })
+}
+
+// protoJSON returns json of proto message with secrets replaced by result of mask.
+func protoJSON(v any, mask func(value any) any) string {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return ""
+	}
+	data, err := protojson.Marshal(msg)
+	if err != nil {
+		return ""
+	}
+	var decoded any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return ""
+	}
+	redacted, err := json.Marshal(redact(decoded, mask))
+	if err != nil {
+		return ""
+	}
+	return string(redacted)
+}
+
+func redact(v any, mask func(value any) any) any {
+	switch value := v.(type) {
+	case map[string]any:
+		for key, field := range value {
+			if secretFields[key] {
+				value[key] = mask(field)
+				continue
+			}
+			value[key] = redact(field, mask)
+		}
+	case []any:
+		for i := range value {
+			value[i] = redact(value[i], mask)
+		}
+	}
+	return v
+}
+
+func newRequestId() string {
+	buf := make([]byte, 8)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// chainUnaryInterceptors combines interceptors into one, the first interceptor is the outermost.
+func chainUnaryInterceptors(interceptors []grpc.UnaryClientInterceptor) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		chained := invoker
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, next := interceptors[i], chained
+			chained = func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				return interceptor(ctx, method, req, reply, cc, next, opts...)
+			}
+		}
+		return chained(ctx, method, req, reply, cc, opts...)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright (c) HashiCorp, Inc.
+
+package headscaleclient
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-log/tflogtest"
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestLoggingInterceptorRedactsSecrets(t *testing.T) {
+	testCases := []struct {
+		name   string
+		method string
+		req    proto.Message
+		reply  proto.Message
+		secret string
+	}{
+		{
+			name:   "pre auth key of response",
+			method: v1.HeadscaleService_CreatePreAuthKey_FullMethodName,
+			req:    &v1.CreatePreAuthKeyRequest{User: 1, Reusable: true},
+			reply: &v1.CreatePreAuthKeyResponse{PreAuthKey: &v1.PreAuthKey{
+				Id:  1,
+				Key: "pre-auth-key-secret",
+			}},
+			secret: "pre-auth-key-secret",
+		},
+		{
+			name:   "pre auth keys of list response",
+			method: v1.HeadscaleService_ListPreAuthKeys_FullMethodName,
+			req:    &v1.ListPreAuthKeysRequest{User: 1},
+			reply: &v1.ListPreAuthKeysResponse{PreAuthKeys: []*v1.PreAuthKey{
+				{Id: 1, Key: "listed-pre-auth-key-secret"},
+			}},
+			secret: "listed-pre-auth-key-secret",
+		},
+		{
+			name:   "pre auth key of request",
+			method: v1.HeadscaleService_ExpirePreAuthKey_FullMethodName,
+			req:    &v1.ExpirePreAuthKeyRequest{User: 1, Key: "expired-pre-auth-key-secret"},
+			reply:  &v1.ExpirePreAuthKeyResponse{},
+			secret: "expired-pre-auth-key-secret",
+		},
+		{
+			name:   "api key of response",
+			method: v1.HeadscaleService_CreateApiKey_FullMethodName,
+			req:    &v1.CreateApiKeyRequest{},
+			reply:  &v1.CreateApiKeyResponse{ApiKey: "api-key-secret"},
+			secret: "api-key-secret",
+		},
+		{
+			name:   "registration key of request",
+			method: v1.HeadscaleService_RegisterNode_FullMethodName,
+			req:    &v1.RegisterNodeRequest{User: "alice", Key: "registration-key-secret"},
+			reply:  &v1.RegisterNodeResponse{Node: &v1.Node{Id: 1, NodeKey: "nodekey:secret"}},
+			secret: "registration-key-secret",
+		},
+		{
+			name:   "node key of response",
+			method: v1.HeadscaleService_GetNode_FullMethodName,
+			req:    &v1.GetNodeRequest{NodeId: 1},
+			reply:  &v1.GetNodeResponse{Node: &v1.Node{Id: 1, NodeKey: "nodekey:secret", MachineKey: "mkey:secret"}},
+			secret: "key:secret",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var output bytes.Buffer
+			ctx := tflogtest.RootLogger(context.Background(), &output)
+			invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				proto.Merge(reply.(proto.Message), testCase.reply)
+				return nil
+			}
+			reply := testCase.reply.ProtoReflect().New().Interface()
+			if err := NewLoggingInterceptor()(ctx, testCase.method, testCase.req, reply, nil, invoker); err != nil {
+				t.Fatalf("call failed: %s", err)
+			}
+
+			entries, err := tflogtest.MultilineJSONDecode(&output)
+			if err != nil {
+				t.Fatalf("cant decode log: %s", err)
+			}
+			var sawRequest, sawResponse bool
+			for _, entry := range entries {
+				sawRequest = sawRequest || entry["headscale_request"] != nil
+				sawResponse = sawResponse || entry["headscale_response"] != nil
+			}
+			if !sawRequest || !sawResponse {
+				t.Fatalf("expected request and response in log, got: %s", output.String())
+			}
+			if strings.Contains(output.String(), testCase.secret) {
+				t.Errorf("secret %q is logged: %s", testCase.secret, output.String())
+			}
+			if !proto.Equal(reply, testCase.reply) {
+				t.Errorf("reply is changed by redaction: %v", reply)
+			}
+		})
+	}
+}
+
+func TestRedactedJSON(t *testing.T) {
+	testCases := []struct {
+		name     string
+		msg      any
+		expected string
+	}{
+		{
+			name:     "api key",
+			msg:      &v1.CreateApiKeyResponse{ApiKey: "secret"},
+			expected: `{"apiKey":"***"}`,
+		},
+		{
+			name:     "nested pre auth key",
+			msg:      &v1.CreatePreAuthKeyResponse{PreAuthKey: &v1.PreAuthKey{Id: 3, Key: "secret", AclTags: []string{"tag:ci"}}},
+			expected: `{"preAuthKey":{"aclTags":["tag:ci"],"id":"3","key":"***"}}`,
+		},
+		{
+			name:     "keys of list items",
+			msg:      &v1.ListNodesResponse{Nodes: []*v1.Node{{Id: 1, NodeKey: "a", DiscoKey: "b", MachineKey: "c"}}},
+			expected: `{"nodes":[{"discoKey":"***","id":"1","machineKey":"***","nodeKey":"***"}]}`,
+		},
+		{
+			name:     "not proto message",
+			msg:      "key",
+			expected: "",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if actual := RedactedJSON(testCase.msg); actual != testCase.expected {
+				t.Errorf("expected %s, got %s", testCase.expected, actual)
+			}
+		})
+	}
+}
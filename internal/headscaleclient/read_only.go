@@ -0,0 +1,61 @@
+// Copyright (c) HashiCorp, Inc.
+
+package headscaleclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+)
+
+// ErrReadOnly is returned by read only interceptor for calls that change headscale.
+var ErrReadOnly = errors.New("call is refused by read only mode")
+
+// mutatingMethodPrefixes are prefixes of headscale methods that change state,
+// "Enable" and "Disable" are legacy routes api of headscale before v0.26.
+var mutatingMethodPrefixes = []string{
+	"Create",
+	"Set",
+	"Delete",
+	"Expire",
+	"Rename",
+	"Move",
+	"Register",
+	"Backfill",
+	"Enable",
+	"Disable",
+	"DebugCreate",
+}
+
+// NewReadOnlyInterceptor returns interceptor that refuses every call that changes headscale with ErrReadOnly,
+// so only List* and Get* calls reach server.
+func NewReadOnlyInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		if IsMutatingMethod(method) {
+			return fmt.Errorf("%w: %s", ErrReadOnly, method)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// IsMutatingMethod reports whether full grpc method name, for example "/headscale.v1.HeadscaleService/SetTags",
+// is a call that changes headscale.
+func IsMutatingMethod(method string) bool {
+	name := method[strings.LastIndex(method, "/")+1:]
+	for _, prefix := range mutatingMethodPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+// Copyright (c) HashiCorp, Inc.
+
+package headscaleclient
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"google.golang.org/grpc"
+)
+
+func TestReadOnlyInterceptor(t *testing.T) {
+	var methods []string
+	for _, prefix := range mutatingMethodPrefixes {
+		methods = append(methods, "/"+headscaleServiceName+"/"+prefix+"Something")
+	}
+	methods = append(methods,
+		v1.HeadscaleService_CreateUser_FullMethodName,
+		v1.HeadscaleService_RenameUser_FullMethodName,
+		v1.HeadscaleService_DeleteUser_FullMethodName,
+		v1.HeadscaleService_CreatePreAuthKey_FullMethodName,
+		v1.HeadscaleService_ExpirePreAuthKey_FullMethodName,
+		v1.HeadscaleService_DebugCreateNode_FullMethodName,
+		v1.HeadscaleService_SetTags_FullMethodName,
+		v1.HeadscaleService_SetApprovedRoutes_FullMethodName,
+		v1.HeadscaleService_RegisterNode_FullMethodName,
+		v1.HeadscaleService_DeleteNode_FullMethodName,
+		v1.HeadscaleService_ExpireNode_FullMethodName,
+		v1.HeadscaleService_RenameNode_FullMethodName,
+		v1.HeadscaleService_MoveNode_FullMethodName,
+		v1.HeadscaleService_BackfillNodeIPs_FullMethodName,
+		v1.HeadscaleService_CreateApiKey_FullMethodName,
+		v1.HeadscaleService_ExpireApiKey_FullMethodName,
+		v1.HeadscaleService_DeleteApiKey_FullMethodName,
+		v1.HeadscaleService_SetPolicy_FullMethodName,
+		legacyEnableRouteMethod,
+		legacyDisableRouteMethod,
+	)
+	allowed := []string{
+		v1.HeadscaleService_ListUsers_FullMethodName,
+		v1.HeadscaleService_ListPreAuthKeys_FullMethodName,
+		v1.HeadscaleService_GetNode_FullMethodName,
+		v1.HeadscaleService_ListNodes_FullMethodName,
+		v1.HeadscaleService_ListApiKeys_FullMethodName,
+		v1.HeadscaleService_GetPolicy_FullMethodName,
+		legacyGetNodeRoutesMethod,
+	}
+
+	interceptor := NewReadOnlyInterceptor()
+	for _, method := range methods {
+		t.Run(method, func(t *testing.T) {
+			invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				t.Errorf("call %s is sent", method)
+				return nil
+			}
+			if err := interceptor(context.Background(), method, nil, nil, nil, invoker); !errors.Is(err, ErrReadOnly) {
+				t.Errorf("expected read only error, got %v", err)
+			}
+		})
+	}
+	for _, method := range allowed {
+		t.Run(method, func(t *testing.T) {
+			sent := false
+			invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				sent = true
+				return nil
+			}
+			if err := interceptor(context.Background(), method, nil, nil, nil, invoker); err != nil || !sent {
+				t.Errorf("expected call to be sent, got error %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,348 @@
+// Copyright (c) HashiCorp, Inc.
+
+package headscaleclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type restClient struct {
+	baseURL     *url.URL
+	httpClient  *http.Client
+	token       string
+	interceptor grpc.UnaryClientInterceptor
+}
+
+// NewRESTClient returns Client that calls headscale's grpc-gateway api "/api/v1".
+// endpoint is base url of headscale, for example "https://headscale.example.com".
+// Interceptors are called with full grpc method name and nil *grpc.ClientConn,
+// so the same interceptors can be used for both protocols.
+func NewRESTClient(
+	endpoint string,
+	httpClient *http.Client,
+	token string,
+	interceptors ...grpc.UnaryClientInterceptor,
+) (Client, error) {
+	baseURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("cant parse endpoint %q: %w", endpoint, err)
+	}
+	if baseURL.Scheme != "http" && baseURL.Scheme != "https" {
+		return nil, fmt.Errorf("endpoint %q must have scheme http or https", endpoint)
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &restClient{
+		baseURL:     baseURL,
+		httpClient:  httpClient,
+		token:       token,
+		interceptor: chainUnaryInterceptors(interceptors),
+	}, nil
+}
+
+// restRoute is http mapping of grpc method, see google.api.http options in headscale.proto.
+type restRoute struct {
+	method string
+	path   string
+	// withBody sends request as json body, otherwise request fields are sent as query parameters.
+	withBody bool
+}
+
+func (c *restClient) call(ctx context.Context, method string, route restRoute, in proto.Message, out proto.Message) error {
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return c.do(ctx, route, in, out, opts...)
+	}
+	return c.interceptor(ctx, method, in, out, nil, invoker)
+}
+
+// peerTrace reports address of connection that request got, like grpc.Peer call option.
+func peerTrace(p *peer.Peer) *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			p.Addr = info.Conn.RemoteAddr()
+		},
+	}
+}
+
+func (c *restClient) do(ctx context.Context, route restRoute, in proto.Message, out proto.Message, opts ...grpc.CallOption) error {
+	u := c.baseURL.JoinPath(route.path)
+	var body io.Reader
+	if route.withBody {
+		data, err := protojson.Marshal(in)
+		if err != nil {
+			return status.Errorf(codes.Internal, "cant marshal request: %s", err)
+		}
+		body = bytes.NewReader(data)
+	} else {
+		u.RawQuery = queryFromMessage(in).Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, route.method, u.String(), body)
+	if err != nil {
+		return status.Errorf(codes.Internal, "cant create request: %s", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if route.withBody {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if md, ok := metadata.FromOutgoingContext(ctx); ok {
+		for key, values := range md {
+			for _, value := range values {
+				req.Header.Add(key, value)
+			}
+		}
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	for _, opt := range opts {
+		if p, ok := opt.(grpc.PeerCallOption); ok {
+			req = req.WithContext(httptrace.WithClientTrace(req.Context(), peerTrace(p.PeerAddr)))
+		}
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		return status.Errorf(codes.Unavailable, "%s %s: %s", route.method, route.path, err)
+	}
+	defer resp.Body.Close()
+	for _, opt := range opts {
+		if header, ok := opt.(grpc.HeaderCallOption); ok {
+			*header.HeaderAddr = headerMetadata(resp.Header)
+		}
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return status.Errorf(codes.Unavailable, "cant read response of %s %s: %s", route.method, route.path, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return restError(resp.StatusCode, data)
+	}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, out); err != nil {
+		return status.Errorf(codes.Internal, "cant unmarshal response of %s %s: %s", route.method, route.path, err)
+	}
+	return nil
+}
+
+func headerMetadata(header http.Header) metadata.MD {
+	md := metadata.MD{}
+	for key, values := range header {
+		md.Append(key, values...)
+	}
+	return md
+}
+
+// restError converts grpc-gateway error response to grpc status error,
+// so errors are handled in the same way for both protocols.
+func restError(httpStatus int, data []byte) error {
+	gatewayError := struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}{}
+	if err := json.Unmarshal(data, &gatewayError); err == nil && gatewayError.Code != 0 {
+		return status.Error(codes.Code(gatewayError.Code), gatewayError.Message)
+	}
+	message := strings.TrimSpace(string(data))
+	if message == "" {
+		message = http.StatusText(httpStatus)
+	}
+	return status.Errorf(codeFromHTTPStatus(httpStatus), "http status %d: %s", httpStatus, message)
+}
+
+func codeFromHTTPStatus(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return codes.Unavailable
+	default:
+		return codes.Unknown
+	}
+}
+
+// queryFromMessage converts populated scalar fields of message to query parameters.
+// Fields that are already part of the path are ignored by grpc-gateway.
+func queryFromMessage(msg proto.Message) url.Values {
+	query := url.Values{}
+	msg.ProtoReflect().Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if fd.IsList() {
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				query.Add(string(fd.Name()), fmt.Sprint(list.Get(i).Interface()))
+			}
+			return true
+		}
+		if fd.Kind() != protoreflect.MessageKind {
+			query.Set(string(fd.Name()), fmt.Sprint(v.Interface()))
+		}
+		return true
+	})
+	return query
+}
+
+func pathID(id uint64) string {
+	return fmt.Sprint(id)
+}
+
+func (c *restClient) CreateUser(ctx context.Context, in *v1.CreateUserRequest) (*v1.CreateUserResponse, error) {
+	out := &v1.CreateUserResponse{}
+	return out, c.call(ctx, v1.HeadscaleService_CreateUser_FullMethodName, restRoute{http.MethodPost, "/api/v1/user", true}, in, out)
+}
+
+func (c *restClient) RenameUser(ctx context.Context, in *v1.RenameUserRequest) (*v1.RenameUserResponse, error) {
+	out := &v1.RenameUserResponse{}
+	path := "/api/v1/user/" + pathID(in.GetOldId()) + "/rename/" + url.PathEscape(in.GetNewName())
+	return out, c.call(ctx, v1.HeadscaleService_RenameUser_FullMethodName, restRoute{http.MethodPost, path, false}, in, out)
+}
+
+func (c *restClient) DeleteUser(ctx context.Context, in *v1.DeleteUserRequest) (*v1.DeleteUserResponse, error) {
+	out := &v1.DeleteUserResponse{}
+	return out, c.call(ctx, v1.HeadscaleService_DeleteUser_FullMethodName, restRoute{http.MethodDelete, "/api/v1/user/" + pathID(in.GetId()), false}, in, out)
+}
+
+func (c *restClient) ListUsers(ctx context.Context, in *v1.ListUsersRequest) (*v1.ListUsersResponse, error) {
+	out := &v1.ListUsersResponse{}
+	return out, c.call(ctx, v1.HeadscaleService_ListUsers_FullMethodName, restRoute{http.MethodGet, "/api/v1/user", false}, in, out)
+}
+
+func (c *restClient) CreatePreAuthKey(ctx context.Context, in *v1.CreatePreAuthKeyRequest) (*v1.CreatePreAuthKeyResponse, error) {
+	out := &v1.CreatePreAuthKeyResponse{}
+	return out, c.call(ctx, v1.HeadscaleService_CreatePreAuthKey_FullMethodName, restRoute{http.MethodPost, "/api/v1/preauthkey", true}, in, out)
+}
+
+func (c *restClient) ExpirePreAuthKey(ctx context.Context, in *v1.ExpirePreAuthKeyRequest) (*v1.ExpirePreAuthKeyResponse, error) {
+	out := &v1.ExpirePreAuthKeyResponse{}
+	return out, c.call(ctx, v1.HeadscaleService_ExpirePreAuthKey_FullMethodName, restRoute{http.MethodPost, "/api/v1/preauthkey/expire", true}, in, out)
+}
+
+func (c *restClient) ListPreAuthKeys(ctx context.Context, in *v1.ListPreAuthKeysRequest) (*v1.ListPreAuthKeysResponse, error) {
+	out := &v1.ListPreAuthKeysResponse{}
+	return out, c.call(ctx, v1.HeadscaleService_ListPreAuthKeys_FullMethodName, restRoute{http.MethodGet, "/api/v1/preauthkey", false}, in, out)
+}
+
+func (c *restClient) DebugCreateNode(ctx context.Context, in *v1.DebugCreateNodeRequest) (*v1.DebugCreateNodeResponse, error) {
+	out := &v1.DebugCreateNodeResponse{}
+	return out, c.call(ctx, v1.HeadscaleService_DebugCreateNode_FullMethodName, restRoute{http.MethodPost, "/api/v1/debug/node", true}, in, out)
+}
+
+func (c *restClient) GetNode(ctx context.Context, in *v1.GetNodeRequest) (*v1.GetNodeResponse, error) {
+	out := &v1.GetNodeResponse{}
+	return out, c.call(ctx, v1.HeadscaleService_GetNode_FullMethodName, restRoute{http.MethodGet, "/api/v1/node/" + pathID(in.GetNodeId()), false}, in, out)
+}
+
+func (c *restClient) SetTags(ctx context.Context, in *v1.SetTagsRequest) (*v1.SetTagsResponse, error) {
+	out := &v1.SetTagsResponse{}
+	path := "/api/v1/node/" + pathID(in.GetNodeId()) + "/tags"
+	return out, c.call(ctx, v1.HeadscaleService_SetTags_FullMethodName, restRoute{http.MethodPost, path, true}, in, out)
+}
+
+func (c *restClient) SetApprovedRoutes(ctx context.Context, in *v1.SetApprovedRoutesRequest) (*v1.SetApprovedRoutesResponse, error) {
+	out := &v1.SetApprovedRoutesResponse{}
+	path := "/api/v1/node/" + pathID(in.GetNodeId()) + "/approve_routes"
+	return out, c.call(ctx, v1.HeadscaleService_SetApprovedRoutes_FullMethodName, restRoute{http.MethodPost, path, true}, in, out)
+}
+
+func (c *restClient) RegisterNode(ctx context.Context, in *v1.RegisterNodeRequest) (*v1.RegisterNodeResponse, error) {
+	out := &v1.RegisterNodeResponse{}
+	return out, c.call(ctx, v1.HeadscaleService_RegisterNode_FullMethodName, restRoute{http.MethodPost, "/api/v1/node/register", false}, in, out)
+}
+
+func (c *restClient) DeleteNode(ctx context.Context, in *v1.DeleteNodeRequest) (*v1.DeleteNodeResponse, error) {
+	out := &v1.DeleteNodeResponse{}
+	return out, c.call(ctx, v1.HeadscaleService_DeleteNode_FullMethodName, restRoute{http.MethodDelete, "/api/v1/node/" + pathID(in.GetNodeId()), false}, in, out)
+}
+
+func (c *restClient) ExpireNode(ctx context.Context, in *v1.ExpireNodeRequest) (*v1.ExpireNodeResponse, error) {
+	out := &v1.ExpireNodeResponse{}
+	path := "/api/v1/node/" + pathID(in.GetNodeId()) + "/expire"
+	return out, c.call(ctx, v1.HeadscaleService_ExpireNode_FullMethodName, restRoute{http.MethodPost, path, false}, in, out)
+}
+
+func (c *restClient) RenameNode(ctx context.Context, in *v1.RenameNodeRequest) (*v1.RenameNodeResponse, error) {
+	out := &v1.RenameNodeResponse{}
+	path := "/api/v1/node/" + pathID(in.GetNodeId()) + "/rename/" + url.PathEscape(in.GetNewName())
+	return out, c.call(ctx, v1.HeadscaleService_RenameNode_FullMethodName, restRoute{http.MethodPost, path, false}, in, out)
+}
+
+func (c *restClient) ListNodes(ctx context.Context, in *v1.ListNodesRequest) (*v1.ListNodesResponse, error) {
+	out := &v1.ListNodesResponse{}
+	return out, c.call(ctx, v1.HeadscaleService_ListNodes_FullMethodName, restRoute{http.MethodGet, "/api/v1/node", false}, in, out)
+}
+
+func (c *restClient) MoveNode(ctx context.Context, in *v1.MoveNodeRequest) (*v1.MoveNodeResponse, error) {
+	out := &v1.MoveNodeResponse{}
+	path := "/api/v1/node/" + pathID(in.GetNodeId()) + "/user"
+	return out, c.call(ctx, v1.HeadscaleService_MoveNode_FullMethodName, restRoute{http.MethodPost, path, true}, in, out)
+}
+
+func (c *restClient) BackfillNodeIPs(ctx context.Context, in *v1.BackfillNodeIPsRequest) (*v1.BackfillNodeIPsResponse, error) {
+	out := &v1.BackfillNodeIPsResponse{}
+	return out, c.call(ctx, v1.HeadscaleService_BackfillNodeIPs_FullMethodName, restRoute{http.MethodPost, "/api/v1/node/backfillips", false}, in, out)
+}
+
+func (c *restClient) CreateApiKey(ctx context.Context, in *v1.CreateApiKeyRequest) (*v1.CreateApiKeyResponse, error) {
+	out := &v1.CreateApiKeyResponse{}
+	return out, c.call(ctx, v1.HeadscaleService_CreateApiKey_FullMethodName, restRoute{http.MethodPost, "/api/v1/apikey", true}, in, out)
+}
+
+func (c *restClient) ExpireApiKey(ctx context.Context, in *v1.ExpireApiKeyRequest) (*v1.ExpireApiKeyResponse, error) {
+	out := &v1.ExpireApiKeyResponse{}
+	return out, c.call(ctx, v1.HeadscaleService_ExpireApiKey_FullMethodName, restRoute{http.MethodPost, "/api/v1/apikey/expire", true}, in, out)
+}
+
+func (c *restClient) ListApiKeys(ctx context.Context, in *v1.ListApiKeysRequest) (*v1.ListApiKeysResponse, error) {
+	out := &v1.ListApiKeysResponse{}
+	return out, c.call(ctx, v1.HeadscaleService_ListApiKeys_FullMethodName, restRoute{http.MethodGet, "/api/v1/apikey", false}, in, out)
+}
+
+func (c *restClient) DeleteApiKey(ctx context.Context, in *v1.DeleteApiKeyRequest) (*v1.DeleteApiKeyResponse, error) {
+	out := &v1.DeleteApiKeyResponse{}
+	path := "/api/v1/apikey/" + url.PathEscape(in.GetPrefix())
+	return out, c.call(ctx, v1.HeadscaleService_DeleteApiKey_FullMethodName, restRoute{http.MethodDelete, path, false}, in, out)
+}
+
+func (c *restClient) GetPolicy(ctx context.Context, in *v1.GetPolicyRequest) (*v1.GetPolicyResponse, error) {
+	out := &v1.GetPolicyResponse{}
+	return out, c.call(ctx, v1.HeadscaleService_GetPolicy_FullMethodName, restRoute{http.MethodGet, "/api/v1/policy", false}, in, out)
+}
+
+func (c *restClient) SetPolicy(ctx context.Context, in *v1.SetPolicyRequest) (*v1.SetPolicyResponse, error) {
+	out := &v1.SetPolicyResponse{}
+	return out, c.call(ctx, v1.HeadscaleService_SetPolicy_FullMethodName, restRoute{http.MethodPut, "/api/v1/policy", true}, in, out)
+}
@@ -0,0 +1,330 @@
+// Copyright (c) HashiCorp, Inc.
+
+package headscaleclient
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// gatewayCall is call that headscale's grpc-gateway made to grpc service.
+type gatewayCall struct {
+	method        string
+	request       proto.Message
+	authorization string
+}
+
+// newGatewayServer starts http server with headscale's generated grpc-gateway handlers,
+// grpc calls of gateway are recorded instead of being sent, so rest routes are checked against real gateway mapping.
+func newGatewayServer(t *testing.T) (*httptest.Server, *[]gatewayCall) {
+	t.Helper()
+	var calls []gatewayCall
+	conn, err := grpc.NewClient(
+		"passthrough:///gateway",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(func(
+			ctx context.Context,
+			method string,
+			req, reply any,
+			cc *grpc.ClientConn,
+			invoker grpc.UnaryInvoker,
+			opts ...grpc.CallOption,
+		) error {
+			md, _ := metadata.FromOutgoingContext(ctx)
+			call := gatewayCall{method: method, request: proto.Clone(req.(proto.Message))}
+			if values := md.Get("authorization"); len(values) > 0 {
+				call.authorization = values[0]
+			}
+			calls = append(calls, call)
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("cant create grpc client: %s", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	mux := runtime.NewServeMux()
+	if err := v1.RegisterHeadscaleServiceHandlerClient(context.Background(), mux, v1.NewHeadscaleServiceClient(conn)); err != nil {
+		t.Fatalf("cant register gateway: %s", err)
+	}
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestRESTClientRoutes(t *testing.T) {
+	expiration := timestamppb.New(time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC))
+	testCases := []struct {
+		method string
+		in     proto.Message
+		call   func(ctx context.Context, client Client, in proto.Message) error
+	}{
+		{
+			method: v1.HeadscaleService_CreateUser_FullMethodName,
+			in:     &v1.CreateUserRequest{Name: "alice", DisplayName: "Alice", Email: "alice@example.com"},
+			call: func(ctx context.Context, client Client, in proto.Message) error {
+				_, err := client.CreateUser(ctx, in.(*v1.CreateUserRequest))
+				return err
+			},
+		},
+		{
+			method: v1.HeadscaleService_RenameUser_FullMethodName,
+			in:     &v1.RenameUserRequest{OldId: 7, NewName: "bob"},
+			call: func(ctx context.Context, client Client, in proto.Message) error {
+				_, err := client.RenameUser(ctx, in.(*v1.RenameUserRequest))
+				return err
+			},
+		},
+		{
+			method: v1.HeadscaleService_DeleteUser_FullMethodName,
+			in:     &v1.DeleteUserRequest{Id: 7},
+			call: func(ctx context.Context, client Client, in proto.Message) error {
+				_, err := client.DeleteUser(ctx, in.(*v1.DeleteUserRequest))
+				return err
+			},
+		},
+		{
+			method: v1.HeadscaleService_ListUsers_FullMethodName,
+			in:     &v1.ListUsersRequest{Name: "alice"},
+			call: func(ctx context.Context, client Client, in proto.Message) error {
+				_, err := client.ListUsers(ctx, in.(*v1.ListUsersRequest))
+				return err
+			},
+		},
+		{
+			method: v1.HeadscaleService_CreatePreAuthKey_FullMethodName,
+			in:     &v1.CreatePreAuthKeyRequest{User: 7, Reusable: true, Expiration: expiration, AclTags: []string{"tag:ci"}},
+			call: func(ctx context.Context, client Client, in proto.Message) error {
+				_, err := client.CreatePreAuthKey(ctx, in.(*v1.CreatePreAuthKeyRequest))
+				return err
+			},
+		},
+		{
+			method: v1.HeadscaleService_ExpirePreAuthKey_FullMethodName,
+			in:     &v1.ExpirePreAuthKeyRequest{User: 7, Key: "secret"},
+			call: func(ctx context.Context, client Client, in proto.Message) error {
+				_, err := client.ExpirePreAuthKey(ctx, in.(*v1.ExpirePreAuthKeyRequest))
+				return err
+			},
+		},
+		{
+			method: v1.HeadscaleService_ListPreAuthKeys_FullMethodName,
+			in:     &v1.ListPreAuthKeysRequest{User: 7},
+			call: func(ctx context.Context, client Client, in proto.Message) error {
+				_, err := client.ListPreAuthKeys(ctx, in.(*v1.ListPreAuthKeysRequest))
+				return err
+			},
+		},
+		{
+			method: v1.HeadscaleService_DebugCreateNode_FullMethodName,
+			in:     &v1.DebugCreateNodeRequest{User: "alice", Key: "mkey:abc", Name: "node", Routes: []string{"10.0.0.0/8"}},
+			call: func(ctx context.Context, client Client, in proto.Message) error {
+				_, err := client.DebugCreateNode(ctx, in.(*v1.DebugCreateNodeRequest))
+				return err
+			},
+		},
+		{
+			method: v1.HeadscaleService_GetNode_FullMethodName,
+			in:     &v1.GetNodeRequest{NodeId: 3},
+			call: func(ctx context.Context, client Client, in proto.Message) error {
+				_, err := client.GetNode(ctx, in.(*v1.GetNodeRequest))
+				return err
+			},
+		},
+		{
+			method: v1.HeadscaleService_SetTags_FullMethodName,
+			in:     &v1.SetTagsRequest{NodeId: 3, Tags: []string{"tag:a", "tag:b"}},
+			call: func(ctx context.Context, client Client, in proto.Message) error {
+				_, err := client.SetTags(ctx, in.(*v1.SetTagsRequest))
+				return err
+			},
+		},
+		{
+			method: v1.HeadscaleService_SetApprovedRoutes_FullMethodName,
+			in:     &v1.SetApprovedRoutesRequest{NodeId: 3, Routes: []string{"10.0.0.0/8", "0.0.0.0/0"}},
+			call: func(ctx context.Context, client Client, in proto.Message) error {
+				_, err := client.SetApprovedRoutes(ctx, in.(*v1.SetApprovedRoutesRequest))
+				return err
+			},
+		},
+		{
+			method: v1.HeadscaleService_RegisterNode_FullMethodName,
+			in:     &v1.RegisterNodeRequest{User: "alice", Key: "registration-key"},
+			call: func(ctx context.Context, client Client, in proto.Message) error {
+				_, err := client.RegisterNode(ctx, in.(*v1.RegisterNodeRequest))
+				return err
+			},
+		},
+		{
+			method: v1.HeadscaleService_DeleteNode_FullMethodName,
+			in:     &v1.DeleteNodeRequest{NodeId: 3},
+			call: func(ctx context.Context, client Client, in proto.Message) error {
+				_, err := client.DeleteNode(ctx, in.(*v1.DeleteNodeRequest))
+				return err
+			},
+		},
+		{
+			method: v1.HeadscaleService_ExpireNode_FullMethodName,
+			in:     &v1.ExpireNodeRequest{NodeId: 3},
+			call: func(ctx context.Context, client Client, in proto.Message) error {
+				_, err := client.ExpireNode(ctx, in.(*v1.ExpireNodeRequest))
+				return err
+			},
+		},
+		{
+			method: v1.HeadscaleService_RenameNode_FullMethodName,
+			in:     &v1.RenameNodeRequest{NodeId: 3, NewName: "renamed"},
+			call: func(ctx context.Context, client Client, in proto.Message) error {
+				_, err := client.RenameNode(ctx, in.(*v1.RenameNodeRequest))
+				return err
+			},
+		},
+		{
+			method: v1.HeadscaleService_ListNodes_FullMethodName,
+			in:     &v1.ListNodesRequest{User: "alice"},
+			call: func(ctx context.Context, client Client, in proto.Message) error {
+				_, err := client.ListNodes(ctx, in.(*v1.ListNodesRequest))
+				return err
+			},
+		},
+		{
+			method: v1.HeadscaleService_MoveNode_FullMethodName,
+			in:     &v1.MoveNodeRequest{NodeId: 3, User: 8},
+			call: func(ctx context.Context, client Client, in proto.Message) error {
+				_, err := client.MoveNode(ctx, in.(*v1.MoveNodeRequest))
+				return err
+			},
+		},
+		{
+			method: v1.HeadscaleService_BackfillNodeIPs_FullMethodName,
+			in:     &v1.BackfillNodeIPsRequest{Confirmed: true},
+			call: func(ctx context.Context, client Client, in proto.Message) error {
+				_, err := client.BackfillNodeIPs(ctx, in.(*v1.BackfillNodeIPsRequest))
+				return err
+			},
+		},
+		{
+			method: v1.HeadscaleService_CreateApiKey_FullMethodName,
+			in:     &v1.CreateApiKeyRequest{Expiration: expiration},
+			call: func(ctx context.Context, client Client, in proto.Message) error {
+				_, err := client.CreateApiKey(ctx, in.(*v1.CreateApiKeyRequest))
+				return err
+			},
+		},
+		{
+			method: v1.HeadscaleService_ExpireApiKey_FullMethodName,
+			in:     &v1.ExpireApiKeyRequest{Prefix: "abcdef"},
+			call: func(ctx context.Context, client Client, in proto.Message) error {
+				_, err := client.ExpireApiKey(ctx, in.(*v1.ExpireApiKeyRequest))
+				return err
+			},
+		},
+		{
+			method: v1.HeadscaleService_ListApiKeys_FullMethodName,
+			in:     &v1.ListApiKeysRequest{},
+			call: func(ctx context.Context, client Client, in proto.Message) error {
+				_, err := client.ListApiKeys(ctx, in.(*v1.ListApiKeysRequest))
+				return err
+			},
+		},
+		{
+			method: v1.HeadscaleService_DeleteApiKey_FullMethodName,
+			in:     &v1.DeleteApiKeyRequest{Prefix: "abcdef"},
+			call: func(ctx context.Context, client Client, in proto.Message) error {
+				_, err := client.DeleteApiKey(ctx, in.(*v1.DeleteApiKeyRequest))
+				return err
+			},
+		},
+		{
+			method: v1.HeadscaleService_GetPolicy_FullMethodName,
+			in:     &v1.GetPolicyRequest{},
+			call: func(ctx context.Context, client Client, in proto.Message) error {
+				_, err := client.GetPolicy(ctx, in.(*v1.GetPolicyRequest))
+				return err
+			},
+		},
+		{
+			method: v1.HeadscaleService_SetPolicy_FullMethodName,
+			in:     &v1.SetPolicyRequest{Policy: `{"acls":[]}`},
+			call: func(ctx context.Context, client Client, in proto.Message) error {
+				_, err := client.SetPolicy(ctx, in.(*v1.SetPolicyRequest))
+				return err
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.method, func(t *testing.T) {
+			server, calls := newGatewayServer(t)
+			client, err := NewRESTClient(server.URL, server.Client(), "api-key")
+			if err != nil {
+				t.Fatalf("cant create rest client: %s", err)
+			}
+			if err := testCase.call(context.Background(), client, testCase.in); err != nil {
+				t.Fatalf("call failed: %s", err)
+			}
+			if len(*calls) != 1 {
+				t.Fatalf("expected 1 grpc call of gateway, got %d", len(*calls))
+			}
+			call := (*calls)[0]
+			if call.method != testCase.method {
+				t.Errorf("expected method %s, got %s", testCase.method, call.method)
+			}
+			if !proto.Equal(call.request, testCase.in) {
+				t.Errorf("expected request %v, got %v", testCase.in, call.request)
+			}
+			if call.authorization != "Bearer api-key" {
+				t.Errorf("expected bearer authorization, got %q", call.authorization)
+			}
+		})
+	}
+}
+
+func TestRESTClientErrors(t *testing.T) {
+	conn, err := grpc.NewClient(
+		"passthrough:///gateway",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(func(
+			ctx context.Context,
+			method string,
+			req, reply any,
+			cc *grpc.ClientConn,
+			invoker grpc.UnaryInvoker,
+			opts ...grpc.CallOption,
+		) error {
+			return status.Error(codes.NotFound, "node not found")
+		}),
+	)
+	if err != nil {
+		t.Fatalf("cant create grpc client: %s", err)
+	}
+	defer conn.Close()
+	mux := runtime.NewServeMux()
+	if err := v1.RegisterHeadscaleServiceHandlerClient(context.Background(), mux, v1.NewHeadscaleServiceClient(conn)); err != nil {
+		t.Fatalf("cant register gateway: %s", err)
+	}
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client, err := NewRESTClient(server.URL, server.Client(), "")
+	if err != nil {
+		t.Fatalf("cant create rest client: %s", err)
+	}
+	_, err = client.GetNode(context.Background(), &v1.GetNodeRequest{NodeId: 3})
+	if status.Code(err) != codes.NotFound || status.Convert(err).Message() != "node not found" {
+		t.Errorf("expected NotFound status with gateway message, got %v", err)
+	}
+}
@@ -0,0 +1,315 @@
+// Copyright (c) HashiCorp, Inc.
+
+package headscaleclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"slices"
+
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// NodeRoutes manages approved routes of node in version specific way.
+type NodeRoutes interface {
+	// ApprovedRoutes returns approved routes of node.
+	ApprovedRoutes(ctx context.Context, node *v1.Node) ([]string, error)
+	// SetApprovedRoutes approves exactly given routes of node and returns approved routes.
+	SetApprovedRoutes(ctx context.Context, nodeId uint64, routes []string) ([]string, error)
+}
+
+// NewNodeRoutes returns NodeRoutes adapter for api of headscale server.
+func NewNodeRoutes(client Client, api *ServerAPI) NodeRoutes {
+	switch api.Routes {
+	case RoutesAPIApproved:
+		return &approvedNodeRoutes{client: client}
+	case RoutesAPILegacy:
+		if legacy, ok := client.(legacyRoutesClient); ok {
+			return &legacyNodeRoutes{client: legacy}
+		}
+	}
+	return &unsupportedNodeRoutes{api: api}
+}
+
+type approvedNodeRoutes struct {
+	client Client
+}
+
+func (r *approvedNodeRoutes) ApprovedRoutes(ctx context.Context, node *v1.Node) ([]string, error) {
+	return node.GetApprovedRoutes(), nil
+}
+
+func (r *approvedNodeRoutes) SetApprovedRoutes(ctx context.Context, nodeId uint64, routes []string) ([]string, error) {
+	// headscale approves any valid prefix, route that node does not advertise is approved but never served
+	if len(routes) > 0 {
+		node, err := r.client.GetNode(ctx, &v1.GetNodeRequest{NodeId: nodeId})
+		if err != nil {
+			return nil, err
+		}
+		if err := checkAdvertised(nodeId, routes, node.GetNode().GetAvailableRoutes()); err != nil {
+			return nil, err
+		}
+	}
+	response, err := r.client.SetApprovedRoutes(ctx, &v1.SetApprovedRoutesRequest{
+		NodeId: nodeId,
+		Routes: routes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response.GetNode().GetApprovedRoutes(), nil
+}
+
+// legacyRoute is route of headscale v0.23-v0.25.
+type legacyRoute struct {
+	Id         uint64
+	Prefix     string
+	Advertised bool
+	Enabled    bool
+}
+
+const (
+	legacyGetNodeRoutesMethod = "/" + headscaleServiceName + "/GetNodeRoutes"
+	legacyEnableRouteMethod   = "/" + headscaleServiceName + "/EnableRoute"
+	legacyDisableRouteMethod  = "/" + headscaleServiceName + "/DisableRoute"
+)
+
+type legacyRoutesClient interface {
+	getNodeRoutes(ctx context.Context, nodeId uint64) ([]legacyRoute, error)
+	enableRoute(ctx context.Context, routeId uint64) error
+	disableRoute(ctx context.Context, routeId uint64) error
+}
+
+type legacyNodeRoutes struct {
+	client legacyRoutesClient
+}
+
+func (r *legacyNodeRoutes) ApprovedRoutes(ctx context.Context, node *v1.Node) ([]string, error) {
+	routes, err := r.client.getNodeRoutes(ctx, node.GetId())
+	if err != nil {
+		return nil, err
+	}
+	return enabledPrefixes(routes), nil
+}
+
+func (r *legacyNodeRoutes) SetApprovedRoutes(ctx context.Context, nodeId uint64, routes []string) ([]string, error) {
+	current, err := r.client.getNodeRoutes(ctx, nodeId)
+	if err != nil {
+		return nil, err
+	}
+	advertised := make([]string, 0, len(current))
+	for _, route := range current {
+		advertised = append(advertised, route.Prefix)
+	}
+	if err := checkAdvertised(nodeId, routes, advertised); err != nil {
+		return nil, err
+	}
+	desired := map[string]bool{}
+	for _, route := range routes {
+		desired[normalizePrefix(route)] = true
+	}
+
+	for _, route := range current {
+		wanted := desired[normalizePrefix(route.Prefix)]
+		switch {
+		case wanted && !route.Enabled:
+			err = r.client.enableRoute(ctx, route.Id)
+		case !wanted && route.Enabled:
+			err = r.client.disableRoute(ctx, route.Id)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	updated, err := r.client.getNodeRoutes(ctx, nodeId)
+	if err != nil {
+		return nil, err
+	}
+	return enabledPrefixes(updated), nil
+}
+
+func enabledPrefixes(routes []legacyRoute) []string {
+	result := make([]string, 0, len(routes))
+	for _, route := range routes {
+		if route.Enabled {
+			result = append(result, route.Prefix)
+		}
+	}
+	slices.Sort(result)
+	return slices.Compact(result)
+}
+
+// RouteNotAdvertisedMessage is part of error message of route that can not be approved, because node does not advertise it.
+const RouteNotAdvertisedMessage = "is not advertised by node"
+
+// checkAdvertised returns FailedPrecondition error for route that is not in advertised routes of node.
+func checkAdvertised(nodeId uint64, routes []string, advertised []string) error {
+	known := make(map[string]bool, len(advertised))
+	for _, route := range advertised {
+		known[normalizePrefix(route)] = true
+	}
+	for _, route := range routes {
+		if !known[normalizePrefix(route)] {
+			return status.Errorf(codes.FailedPrecondition, "route %s %s %d", normalizePrefix(route), RouteNotAdvertisedMessage, nodeId)
+		}
+	}
+	return nil
+}
+
+func normalizePrefix(route string) string {
+	prefix, err := netip.ParsePrefix(route)
+	if err != nil {
+		return route
+	}
+	return prefix.Masked().String()
+}
+
+type unsupportedNodeRoutes struct {
+	api *ServerAPI
+}
+
+func (r *unsupportedNodeRoutes) ApprovedRoutes(ctx context.Context, node *v1.Node) ([]string, error) {
+	return nil, r.err()
+}
+
+func (r *unsupportedNodeRoutes) SetApprovedRoutes(ctx context.Context, nodeId uint64, routes []string) ([]string, error) {
+	return nil, r.err()
+}
+
+func (r *unsupportedNodeRoutes) err() error {
+	return fmt.Errorf(
+		"%w: routes api %q (detected by %s) is not known by provider",
+		ErrUnsupportedOperation,
+		r.api.Routes,
+		r.api.Source,
+	)
+}
+
+// legacyRoutesFile describes messages of routes api of headscale v0.23-v0.25 that are absent in v0.26 protos.
+// Only fields that are used by provider are described, others are kept as unknown fields.
+var legacyRoutesFile = func() protoreflect.FileDescriptor {
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Type:   typ.Enum(),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+	}
+	uint64Field := func(name string, number int32) *descriptorpb.FieldDescriptorProto {
+		return field(name, number, descriptorpb.FieldDescriptorProto_TYPE_UINT64)
+	}
+	empty := func(name string) *descriptorpb.DescriptorProto {
+		return &descriptorpb.DescriptorProto{Name: proto.String(name)}
+	}
+	routesField := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String("routes"),
+		Number:   proto.Int32(1),
+		Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+		TypeName: proto.String(".headscale.v1.legacy.Route"),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+	}
+
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("headscale/v1/legacy/routes.proto"),
+		Package: proto.String("headscale.v1.legacy"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Route"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					uint64Field("id", 1),
+					field("prefix", 3, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+					field("advertised", 4, descriptorpb.FieldDescriptorProto_TYPE_BOOL),
+					field("enabled", 5, descriptorpb.FieldDescriptorProto_TYPE_BOOL),
+				},
+			},
+			{Name: proto.String("GetNodeRoutesRequest"), Field: []*descriptorpb.FieldDescriptorProto{uint64Field("node_id", 1)}},
+			{Name: proto.String("GetNodeRoutesResponse"), Field: []*descriptorpb.FieldDescriptorProto{routesField}},
+			{Name: proto.String("EnableRouteRequest"), Field: []*descriptorpb.FieldDescriptorProto{uint64Field("route_id", 1)}},
+			empty("EnableRouteResponse"),
+			{Name: proto.String("DisableRouteRequest"), Field: []*descriptorpb.FieldDescriptorProto{uint64Field("route_id", 1)}},
+			empty("DisableRouteResponse"),
+		},
+	}, nil)
+	if err != nil {
+		panic(fmt.Sprintf("invalid legacy routes descriptor: %s", err))
+	}
+	return file
+}()
+
+func newLegacyMessage(name protoreflect.Name) *dynamicpb.Message {
+	return dynamicpb.NewMessage(legacyRoutesFile.Messages().ByName(name))
+}
+
+func setUint64(msg *dynamicpb.Message, name protoreflect.Name, value uint64) {
+	msg.Set(msg.Descriptor().Fields().ByName(name), protoreflect.ValueOfUint64(value))
+}
+
+func legacyRoutesFromResponse(msg *dynamicpb.Message) []legacyRoute {
+	routeFields := legacyRoutesFile.Messages().ByName("Route").Fields()
+	list := msg.Get(msg.Descriptor().Fields().ByName("routes")).List()
+	result := make([]legacyRoute, 0, list.Len())
+	for i := 0; i < list.Len(); i++ {
+		route := list.Get(i).Message()
+		result = append(result, legacyRoute{
+			Id:         route.Get(routeFields.ByName("id")).Uint(),
+			Prefix:     route.Get(routeFields.ByName("prefix")).String(),
+			Advertised: route.Get(routeFields.ByName("advertised")).Bool(),
+			Enabled:    route.Get(routeFields.ByName("enabled")).Bool(),
+		})
+	}
+	return result
+}
+
+func (c *grpcClient) getNodeRoutes(ctx context.Context, nodeId uint64) ([]legacyRoute, error) {
+	in := newLegacyMessage("GetNodeRoutesRequest")
+	setUint64(in, "node_id", nodeId)
+	out := newLegacyMessage("GetNodeRoutesResponse")
+	if err := c.conn.Invoke(ctx, legacyGetNodeRoutesMethod, in, out); err != nil {
+		return nil, err
+	}
+	return legacyRoutesFromResponse(out), nil
+}
+
+func (c *grpcClient) enableRoute(ctx context.Context, routeId uint64) error {
+	in := newLegacyMessage("EnableRouteRequest")
+	setUint64(in, "route_id", routeId)
+	return c.conn.Invoke(ctx, legacyEnableRouteMethod, in, newLegacyMessage("EnableRouteResponse"))
+}
+
+func (c *grpcClient) disableRoute(ctx context.Context, routeId uint64) error {
+	in := newLegacyMessage("DisableRouteRequest")
+	setUint64(in, "route_id", routeId)
+	return c.conn.Invoke(ctx, legacyDisableRouteMethod, in, newLegacyMessage("DisableRouteResponse"))
+}
+
+func (c *restClient) getNodeRoutes(ctx context.Context, nodeId uint64) ([]legacyRoute, error) {
+	in := newLegacyMessage("GetNodeRoutesRequest")
+	out := newLegacyMessage("GetNodeRoutesResponse")
+	path := "/api/v1/node/" + pathID(nodeId) + "/routes"
+	if err := c.call(ctx, legacyGetNodeRoutesMethod, restRoute{http.MethodGet, path, false}, in, out); err != nil {
+		return nil, err
+	}
+	return legacyRoutesFromResponse(out), nil
+}
+
+func (c *restClient) enableRoute(ctx context.Context, routeId uint64) error {
+	path := "/api/v1/routes/" + pathID(routeId) + "/enable"
+	return c.call(ctx, legacyEnableRouteMethod, restRoute{http.MethodPost, path, false}, newLegacyMessage("EnableRouteRequest"), newLegacyMessage("EnableRouteResponse"))
+}
+
+func (c *restClient) disableRoute(ctx context.Context, routeId uint64) error {
+	path := "/api/v1/routes/" + pathID(routeId) + "/disable"
+	return c.call(ctx, legacyDisableRouteMethod, restRoute{http.MethodPost, path, false}, newLegacyMessage("DisableRouteRequest"), newLegacyMessage("DisableRouteResponse"))
+}
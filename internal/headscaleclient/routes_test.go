@@ -0,0 +1,87 @@
+// Copyright (c) HashiCorp, Inc.
+
+package headscaleclient
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// routesNode is client of headscale with one node that advertises routes.
+type routesNode struct {
+	Client
+
+	advertised []string
+	getNode    int
+	approved   []string
+}
+
+func (n *routesNode) GetNode(ctx context.Context, in *v1.GetNodeRequest) (*v1.GetNodeResponse, error) {
+	n.getNode++
+	return &v1.GetNodeResponse{Node: &v1.Node{Id: in.GetNodeId(), AvailableRoutes: n.advertised}}, nil
+}
+
+func (n *routesNode) SetApprovedRoutes(ctx context.Context, in *v1.SetApprovedRoutesRequest) (*v1.SetApprovedRoutesResponse, error) {
+	n.approved = in.GetRoutes()
+	return &v1.SetApprovedRoutesResponse{Node: &v1.Node{Id: in.GetNodeId(), ApprovedRoutes: in.GetRoutes()}}, nil
+}
+
+func TestApprovedNodeRoutesChecksAdvertisedRoutes(t *testing.T) {
+	testCases := []struct {
+		name     string
+		routes   []string
+		getNode  int
+		approved bool
+		error    string
+	}{
+		{
+			name:     "advertised routes",
+			routes:   []string{"10.0.0.0/24", "0.0.0.0/0"},
+			getNode:  1,
+			approved: true,
+		},
+		{
+			name:     "route that is not masked",
+			routes:   []string{"10.0.0.1/24"},
+			getNode:  1,
+			approved: true,
+		},
+		{
+			name:    "route that is not advertised",
+			routes:  []string{"10.0.0.0/24", "192.168.0.0/24"},
+			getNode: 1,
+			error:   "route 192.168.0.0/24 is not advertised by node 5",
+		},
+		{
+			name:     "removal of routes",
+			approved: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			node := &routesNode{advertised: []string{"10.0.0.0/24", "0.0.0.0/0", "::/0"}}
+			routes := NewNodeRoutes(node, &ServerAPI{Routes: RoutesAPIApproved})
+			_, err := routes.SetApprovedRoutes(context.Background(), 5, testCase.routes)
+			if testCase.error != "" {
+				if status.Code(err) != codes.FailedPrecondition || !strings.Contains(err.Error(), testCase.error) {
+					t.Errorf("expected failed precondition %q, got %v", testCase.error, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if node.getNode != testCase.getNode {
+				t.Errorf("expected %d GetNode calls, got %d", testCase.getNode, node.getNode)
+			}
+			if testCase.approved != slices.Equal(node.approved, testCase.routes) || (!testCase.approved && node.approved != nil) {
+				t.Errorf("unexpected approved routes %v", node.approved)
+			}
+		})
+	}
+}
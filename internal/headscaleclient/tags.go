@@ -0,0 +1,65 @@
+// Copyright (c) HashiCorp, Inc.
+
+package headscaleclient
+
+import (
+	"context"
+	"fmt"
+
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+)
+
+// NodeTags manages tags of node that are set by admin in version specific way.
+type NodeTags interface {
+	// Tags returns tags of node that are set by admin.
+	Tags(ctx context.Context, node *v1.Node) ([]string, error)
+	// SetTags sets exactly given tags of node and returns tags of node.
+	SetTags(ctx context.Context, nodeId uint64, tags []string) ([]string, error)
+}
+
+// NewNodeTags returns NodeTags adapter for api of headscale server.
+func NewNodeTags(client Client, api *ServerAPI) NodeTags {
+	if api.Tags == TagsAPIForced {
+		return &forcedNodeTags{client: client}
+	}
+	return &unsupportedNodeTags{api: api}
+}
+
+type forcedNodeTags struct {
+	client Client
+}
+
+func (t *forcedNodeTags) Tags(ctx context.Context, node *v1.Node) ([]string, error) {
+	return node.GetForcedTags(), nil
+}
+
+func (t *forcedNodeTags) SetTags(ctx context.Context, nodeId uint64, tags []string) ([]string, error) {
+	response, err := t.client.SetTags(ctx, &v1.SetTagsRequest{
+		NodeId: nodeId,
+		Tags:   tags,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response.GetNode().GetForcedTags(), nil
+}
+
+type unsupportedNodeTags struct {
+	api *ServerAPI
+}
+
+func (t *unsupportedNodeTags) Tags(ctx context.Context, node *v1.Node) ([]string, error) {
+	return nil, t.err()
+}
+
+func (t *unsupportedNodeTags) SetTags(ctx context.Context, nodeId uint64, tags []string) ([]string, error) {
+	return nil, t.err()
+}
+
+func (t *unsupportedNodeTags) err() error {
+	return fmt.Errorf(
+		"%w: tags api of server (detected by %s) has no forced tags, new tag model of headscale is not supported yet",
+		ErrUnsupportedOperation,
+		t.api.Source,
+	)
+}
@@ -0,0 +1,91 @@
+// Copyright (c) HashiCorp, Inc.
+
+package headscaleclient
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TracerName is name of opentelemetry tracer of provider.
+const TracerName = "github.com/paragor/terraform-provider-headscale"
+
+// NewTracingInterceptor returns interceptor that records client span for every headscale call
+// with attributes of opentelemetry rpc conventions and propagates trace context to headscale in grpc metadata.
+// Spans are recorded by global tracer provider, so interceptor is no-op until it is set.
+func NewTracingInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		service, name := splitMethod(method)
+		ctx, span := otel.Tracer(TracerName).Start(
+			ctx,
+			strings.TrimPrefix(method, "/"),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("rpc.system", "grpc"),
+				attribute.String("rpc.service", service),
+				attribute.String("rpc.method", name),
+			),
+		)
+		defer span.End()
+
+		md, _ := metadata.FromOutgoingContext(ctx)
+		md = md.Copy()
+		otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(md))
+		ctx = metadata.NewOutgoingContext(ctx, md)
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		st := status.Convert(err)
+		span.SetAttributes(attribute.Int64("rpc.grpc.status_code", int64(st.Code())))
+		if err != nil {
+			span.SetStatus(otelcodes.Error, st.Message())
+		}
+		return err
+	}
+}
+
+// splitMethod splits full grpc method name "/headscale.v1.HeadscaleService/ListUsers" into service and method.
+func splitMethod(method string) (service string, name string) {
+	method = strings.TrimPrefix(method, "/")
+	if i := strings.LastIndex(method, "/"); i >= 0 {
+		return method[:i], method[i+1:]
+	}
+	return "", method
+}
+
+// metadataCarrier adapts grpc metadata to carrier of opentelemetry propagator.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (c metadataCarrier) Set(key string, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,184 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/paragor/terraform-provider-headscale/internal/headscaleclient"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ ephemeral.EphemeralResource = &ApiKeyEphemeralResource{}
+var _ ephemeral.EphemeralResourceWithConfigure = &ApiKeyEphemeralResource{}
+var _ ephemeral.EphemeralResourceWithClose = &ApiKeyEphemeralResource{}
+
+const apiKeyPrivateKey = "api_key"
+
+func NewApiKeyEphemeralResource() ephemeral.EphemeralResource {
+	return &ApiKeyEphemeralResource{}
+}
+
+// ApiKeyEphemeralResource defines the ephemeral resource implementation.
+type ApiKeyEphemeralResource struct {
+	client headscaleclient.Client
+}
+
+type ApiKeyEphemeralResourceModel struct {
+	Ttl           types.String `tfsdk:"ttl"`
+	ExpireOnClose types.Bool   `tfsdk:"expire_on_close"`
+
+	Id         types.String `tfsdk:"id"`
+	Expiration types.String `tfsdk:"expiration"`
+	Key        types.String `tfsdk:"key"`
+}
+
+// apiKeyPrivate is private data of ephemeral api key that is passed to Close.
+type apiKeyPrivate struct {
+	Prefix        string `json:"prefix"`
+	ExpireOnClose bool   `json:"expire_on_close"`
+}
+
+func (r *ApiKeyEphemeralResource) Metadata(ctx context.Context, req ephemeral.MetadataRequest, resp *ephemeral.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_api_key"
+}
+
+func (r *ApiKeyEphemeralResource) Schema(ctx context.Context, req ephemeral.SchemaRequest, resp *ephemeral.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		// This description is used by the documentation generator and the language server.
+		MarkdownDescription: "The ephemeral api key creates short-lived api key during terraform run, for example for another provider or CI job. The key is never stored in state or plan files. Terraform opens ephemeral resource on every plan and apply, so new key is created on every run.",
+
+		Attributes: map[string]schema.Attribute{
+			"ttl": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: `The time until the key expires. Valid time units are "ns", "us" (or "µs"), "ms", "s", "m", "h". Defaults to "1h"`,
+				Validators: []validator.String{
+					ttlValidator(),
+				},
+			},
+			"expire_on_close": schema.BoolAttribute{
+				MarkdownDescription: "Expire the key when terraform closes ephemeral resource at the end of plan or apply. Enable it if the key is used only during the run, for example by another provider. Defaults to false",
+				Optional:            true,
+			},
+
+			"id": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "Prefix of api key",
+			},
+			"key": schema.StringAttribute{
+				Computed:    true,
+				Description: "The api key.",
+				Sensitive:   true,
+			},
+			"expiration": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "expiration of api key",
+			},
+		},
+	}
+}
+
+func (r *ApiKeyEphemeralResource) Configure(ctx context.Context, req ephemeral.ConfigureRequest, resp *ephemeral.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	config, ok := req.ProviderData.(*HeadscaleProviderConfiguration)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Ephemeral Resource Configure Type",
+			fmt.Sprintf("Expected *HeadscaleProviderConfiguration, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+
+		return
+	}
+
+	r.client = config.client
+}
+
+func (r *ApiKeyEphemeralResource) Open(ctx context.Context, req ephemeral.OpenRequest, resp *ephemeral.OpenResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_api_key", "open")
+	defer endOperation(&resp.Diagnostics)
+	var data ApiKeyEphemeralResourceModel
+
+	// Read Terraform config data into the model
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ttl := time.Hour
+	if !data.Ttl.IsNull() {
+		var err error
+		ttl, err = time.ParseDuration(data.Ttl.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddError("Parse TTL Error", fmt.Sprintf("Unable to parse ttl, got error: %s", err))
+			return
+		}
+	}
+	expiration := time.Now().Add(ttl)
+	response, err := r.client.CreateApiKey(ctx, &v1.CreateApiKeyRequest{
+		Expiration: timestamppb.New(expiration),
+	})
+	if err != nil {
+		addClientError(&resp.Diagnostics, "create api key", err)
+		return
+	}
+	keyPrefix := strings.Split(response.GetApiKey(), ".")[0]
+	data.Id = types.StringValue(keyPrefix)
+	data.Key = types.StringValue(response.GetApiKey())
+	data.Expiration = types.StringValue(expiration.Format(time.RFC3339))
+
+	private, err := json.Marshal(apiKeyPrivate{
+		Prefix:        keyPrefix,
+		ExpireOnClose: data.ExpireOnClose.ValueBool(),
+	})
+	if err != nil {
+		resp.Diagnostics.AddError("Private Data Error", fmt.Sprintf("Unable to marshal private data, got error: %s", err))
+		return
+	}
+	resp.Diagnostics.Append(resp.Private.SetKey(ctx, apiKeyPrivateKey, private)...)
+
+	// Save data into ephemeral result data
+	resp.Diagnostics.Append(resp.Result.Set(ctx, &data)...)
+}
+
+func (r *ApiKeyEphemeralResource) Close(ctx context.Context, req ephemeral.CloseRequest, resp *ephemeral.CloseResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_api_key", "close")
+	defer endOperation(&resp.Diagnostics)
+	raw, diags := req.Private.GetKey(ctx, apiKeyPrivateKey)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() || raw == nil {
+		return
+	}
+
+	var private apiKeyPrivate
+	if err := json.Unmarshal(raw, &private); err != nil {
+		resp.Diagnostics.AddError("Private Data Error", fmt.Sprintf("Unable to unmarshal private data, got error: %s", err))
+		return
+	}
+	if !private.ExpireOnClose {
+		return
+	}
+
+	_, err := r.client.ExpireApiKey(ctx, &v1.ExpireApiKeyRequest{
+		Prefix: private.Prefix,
+	})
+	if err != nil {
+		addClientError(&resp.Diagnostics, "expire api key", err)
+		return
+	}
+}
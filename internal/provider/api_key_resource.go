@@ -6,11 +6,9 @@ package provider
 import (
 	"context"
 	"fmt"
-	"regexp"
 	"strings"
 	"time"
 
-	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/boolplanmodifier"
@@ -20,11 +18,13 @@ import (
 	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/paragor/terraform-provider-headscale/internal/headscaleclient"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // Ensure provider defined types fully satisfy framework interfaces.
 var _ resource.Resource = &ApiKeyResource{}
+var _ resource.ResourceWithUpgradeState = &ApiKeyResource{}
 
 func NewApiKeyResource() resource.Resource {
 	return &ApiKeyResource{}
@@ -32,7 +32,7 @@ func NewApiKeyResource() resource.Resource {
 
 // ApiKeyResource defines the resource implementation.
 type ApiKeyResource struct {
-	client v1.HeadscaleServiceClient
+	client headscaleclient.Client
 }
 
 type ApiKeyResourceModel struct {
@@ -52,6 +52,8 @@ func (r *ApiKeyResource) Metadata(ctx context.Context, req resource.MetadataRequ
 
 func (r *ApiKeyResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
+		Version: apiKeyResourceVersion,
+
 		// This description is used by the documentation generator and the language server.
 		MarkdownDescription: "The api key resource allows you to make a api calls to headscale as admin",
 
@@ -73,7 +75,7 @@ func (r *ApiKeyResource) Schema(ctx context.Context, req resource.SchemaRequest,
 					stringplanmodifier.RequiresReplace(),
 				},
 				Validators: []validator.String{
-					stringvalidator.RegexMatches(regexp.MustCompile(`^\d+(ns|us|µs|ms|s|m|h)$`), `Valid time units are "ns", "us" (or "µs"), "ms", "s", "m", "h"`),
+					ttlValidator(),
 				},
 			},
 			"expired": schema.BoolAttribute{
@@ -153,6 +155,8 @@ func (r *ApiKeyResource) readComputedFields(
 }
 
 func (r *ApiKeyResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_api_key", "create")
+	defer endOperation(&resp.Diagnostics)
 	var data ApiKeyResourceModel
 
 	// Read Terraform plan data into the model
@@ -171,7 +175,7 @@ func (r *ApiKeyResource) Create(ctx context.Context, req resource.CreateRequest,
 		Expiration: timestamppb.New(time.Now().Add(ttl)),
 	})
 	if err != nil {
-		resp.Diagnostics.AddError("Client Error", fmt.Sprintf("Unable to create api key, got error: %s", err))
+		addClientError(&resp.Diagnostics, "create api key", err)
 		return
 	}
 	keyPrefix := strings.Split(response.GetApiKey(), ".")[0]
@@ -180,9 +184,7 @@ func (r *ApiKeyResource) Create(ctx context.Context, req resource.CreateRequest,
 
 	listResponse, err := r.client.ListApiKeys(ctx, &v1.ListApiKeysRequest{})
 	if err != nil {
-		resp.Diagnostics.AddError("Client Error", fmt.Sprintf(
-			"Unable to list api keys after creation, got error: %s", err),
-		)
+		addClientError(&resp.Diagnostics, "list api keys after creation", err)
 		return
 	}
 	if isFound := r.readComputedFields(data.Id.ValueString(), listResponse, &data); !isFound {
@@ -194,6 +196,8 @@ func (r *ApiKeyResource) Create(ctx context.Context, req resource.CreateRequest,
 }
 
 func (r *ApiKeyResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_api_key", "read")
+	defer endOperation(&resp.Diagnostics)
 	var data ApiKeyResourceModel
 
 	// Read Terraform prior state data into the model
@@ -205,13 +209,11 @@ func (r *ApiKeyResource) Read(ctx context.Context, req resource.ReadRequest, res
 
 	listResponse, err := r.client.ListApiKeys(ctx, &v1.ListApiKeysRequest{})
 	if err != nil {
-		resp.Diagnostics.AddError("Client Error", fmt.Sprintf(
-			"Unable to list api keys, got error: %s", err),
-		)
+		addClientError(&resp.Diagnostics, "list api keys", err)
 		return
 	}
 	if isFound := r.readComputedFields(data.Id.ValueString(), listResponse, &data); !isFound {
-		resp.State.RemoveResource(ctx)
+		removeNotFoundResource(ctx, &resp.State, &resp.Diagnostics, fmt.Sprintf("api key %q", data.Id.ValueString()))
 		return
 	}
 
@@ -230,6 +232,8 @@ func (r *ApiKeyResource) Update(ctx context.Context, req resource.UpdateRequest,
 }
 
 func (r *ApiKeyResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_api_key", "delete")
+	defer endOperation(&resp.Diagnostics)
 	var data ApiKeyResourceModel
 
 	// Read Terraform prior state data into the model
@@ -242,8 +246,35 @@ func (r *ApiKeyResource) Delete(ctx context.Context, req resource.DeleteRequest,
 	_, err := r.client.DeleteApiKey(ctx, &v1.DeleteApiKeyRequest{
 		Prefix: data.Id.ValueString(),
 	})
-	if err != nil {
-		resp.Diagnostics.AddError("Client Error", fmt.Sprintf("Unable to delete api key, got error: %s", err))
+	if err != nil && !headscaleclient.IsNotFound(err) {
+		addClientError(&resp.Diagnostics, "delete api key", err)
 		return
 	}
 }
+
+func (r *ApiKeyResource) UpgradeState(ctx context.Context) map[int64]resource.StateUpgrader {
+	return map[int64]resource.StateUpgrader{
+		0: {
+			PriorSchema: &schema.Schema{
+				Attributes: map[string]schema.Attribute{
+					"id":         schema.StringAttribute{Computed: true},
+					"ttl":        schema.StringAttribute{Optional: true, Computed: true},
+					"expired":    schema.BoolAttribute{Optional: true, Computed: true},
+					"key":        schema.StringAttribute{Computed: true, Sensitive: true},
+					"expiration": schema.StringAttribute{Optional: true, Computed: true},
+					"created_at": schema.StringAttribute{Computed: true},
+				},
+			},
+			StateUpgrader: func(ctx context.Context, req resource.UpgradeStateRequest, resp *resource.UpgradeStateResponse) {
+				var data ApiKeyResourceModel
+				resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+				if resp.Diagnostics.HasError() {
+					return
+				}
+				data.CreatedAt = upgradeTimestamp(data.CreatedAt)
+				data.Expiration = upgradeTimestamp(data.Expiration)
+				resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+			},
+		},
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/action"
+	"github.com/hashicorp/terraform-plugin-framework/action/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/paragor/terraform-provider-headscale/internal/headscaleclient"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ action.Action = &BackfillNodeIPsAction{}
+var _ action.ActionWithConfigure = &BackfillNodeIPsAction{}
+
+func NewBackfillNodeIPsAction() action.Action {
+	return &BackfillNodeIPsAction{}
+}
+
+// BackfillNodeIPsAction defines the action implementation.
+type BackfillNodeIPsAction struct {
+	client headscaleclient.Client
+	cache  *snapshotCache
+}
+
+type BackfillNodeIPsActionModel struct {
+	Confirm types.Bool `tfsdk:"confirm"`
+}
+
+func (a *BackfillNodeIPsAction) Metadata(ctx context.Context, req action.MetadataRequest, resp *action.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_backfill_node_ips"
+}
+
+func (a *BackfillNodeIPsAction) Schema(ctx context.Context, req action.SchemaRequest, resp *action.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		// This description is used by the documentation generator and the language server.
+		MarkdownDescription: "The action assigns missing IP addresses to all nodes after change of `prefixes` in headscale config, like `headscale nodes backfillips`.",
+
+		Attributes: map[string]schema.Attribute{
+			"confirm": schema.BoolAttribute{
+				Required:            true,
+				MarkdownDescription: "Must be `true`, the action changes IP addresses of nodes.",
+				Validators: []validator.Bool{
+					confirmedValidator{},
+				},
+			},
+		},
+	}
+}
+
+func (a *BackfillNodeIPsAction) Configure(ctx context.Context, req action.ConfigureRequest, resp *action.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	config, ok := req.ProviderData.(*HeadscaleProviderConfiguration)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Action Configure Type",
+			fmt.Sprintf("Expected *HeadscaleProviderConfiguration, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+
+		return
+	}
+
+	a.client = config.client
+	a.cache = config.cache
+}
+
+func (a *BackfillNodeIPsAction) Invoke(ctx context.Context, req action.InvokeRequest, resp *action.InvokeResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_backfill_node_ips", "invoke")
+	defer endOperation(&resp.Diagnostics)
+	var data BackfillNodeIPsActionModel
+
+	// Read Terraform config data into the model
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	response, err := a.client.BackfillNodeIPs(ctx, &v1.BackfillNodeIPsRequest{
+		Confirmed: data.Confirm.ValueBool(),
+	})
+	a.cache.InvalidateNodes()
+	if err != nil {
+		addClientError(&resp.Diagnostics, "backfill node ips", err)
+		return
+	}
+	if len(response.GetChanges()) == 0 {
+		resp.SendProgress(action.InvokeProgressEvent{Message: "all nodes already have IP addresses"})
+	}
+	for _, change := range response.GetChanges() {
+		resp.SendProgress(action.InvokeProgressEvent{Message: change})
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/paragor/terraform-provider-headscale/internal/headscaleclient"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	defaultUnixSocket      = "/var/run/headscale/headscale.sock"
+	defaultBootstrapTTL    = "2160h"
+	bootstrapApiKeyTimeout = 30 * time.Second
+)
+
+// BootstrapModel describes creation of the first api key of fresh headscale over its local unix socket.
+type BootstrapModel struct {
+	UnixSocket types.String    `tfsdk:"unix_socket"`
+	OutputFile types.String    `tfsdk:"output_file"`
+	Ttl        types.String    `tfsdk:"ttl"`
+	SSHTunnel  *SSHTunnelModel `tfsdk:"ssh_tunnel"`
+}
+
+func bootstrapAttribute() schema.SingleNestedAttribute {
+	return schema.SingleNestedAttribute{
+		MarkdownDescription: `
+Bootstrap the first api key of fresh headscale. It is used only if api key is not configured by any other source.
+Provider creates api key over unauthenticated local unix socket of headscale, directly or through "ssh_tunnel",
+writes it to "output_file" and continues over configured endpoint with this key.
+If "output_file" already has a key, provider uses it and does not create a new one.
+`,
+		Optional: true,
+		Attributes: map[string]schema.Attribute{
+			"unix_socket": schema.StringAttribute{
+				MarkdownDescription: `Path of headscale unix socket. Defaults to "` + defaultUnixSocket + `"`,
+				Optional:            true,
+			},
+			"output_file": schema.StringAttribute{
+				MarkdownDescription: "File to write created api key to, it is created with mode 0600. Keep it secret, for example outside of repository.",
+				Required:            true,
+			},
+			"ttl": schema.StringAttribute{
+				MarkdownDescription: `The time until the key expires. Valid time units are "ns", "us" (or "µs"), "ms", "s", "m", "h". Defaults to "` + defaultBootstrapTTL + `" that equal 90 days`,
+				Optional:            true,
+				Validators: []validator.String{
+					ttlValidator(),
+				},
+			},
+			"ssh_tunnel": sshTunnelAttribute("Reach unix socket on headscale host through ssh. If it is not set, unix socket is local."),
+		},
+	}
+}
+
+// bootstrapApiKey returns api key from output file of bootstrap or creates it over unix socket,
+// in read only mode key is only read from output file.
+func (p *HeadscaleProvider) bootstrapApiKey(
+	ctx context.Context,
+	bootstrap *BootstrapModel,
+	proxyURL string,
+	readOnly bool,
+	interceptors []grpc.UnaryClientInterceptor,
+	diags *diag.Diagnostics,
+) string {
+	outputFile := bootstrap.OutputFile.ValueString()
+	data, err := os.ReadFile(outputFile)
+	switch {
+	case err == nil && strings.TrimSpace(string(data)) != "":
+		tflog.Info(ctx, "headscale api key is read from bootstrap output file", map[string]interface{}{
+			"output_file": outputFile,
+		})
+		return strings.TrimSpace(string(data))
+	case err != nil && !errors.Is(err, fs.ErrNotExist):
+		diags.AddAttributeError(
+			path.Root("bootstrap").AtName("output_file"),
+			"Bootstrap Error",
+			fmt.Sprintf("Unable to read api key from %s, got error: %s", outputFile, err),
+		)
+		return ""
+	case readOnly:
+		diags.AddAttributeError(
+			path.Root("bootstrap").AtName("output_file"),
+			"Bootstrap Error",
+			fmt.Sprintf(
+				"Api key is not found in %s, provider does not create api key in read only mode. "+
+					"Bootstrap it by run without read_only or set api_key",
+				outputFile,
+			),
+		)
+		return ""
+	}
+
+	ttl, err := time.ParseDuration(defaultBootstrapTTL)
+	if !bootstrap.Ttl.IsNull() {
+		ttl, err = time.ParseDuration(bootstrap.Ttl.ValueString())
+	}
+	if err != nil {
+		diags.AddAttributeError(path.Root("bootstrap").AtName("ttl"), "Parse TTL Error", fmt.Sprintf("Unable to parse ttl, got error: %s", err))
+		return ""
+	}
+
+	var dialer headscaleclient.DialContextFunc
+	if bootstrap.SSHTunnel != nil {
+		forward := p.proxyDialer(proxyURL, diags)
+		if diags.HasError() {
+			return ""
+		}
+		dialer = p.sshDialer(bootstrap.SSHTunnel, forward, path.Root("bootstrap").AtName("ssh_tunnel"), diags)
+		if diags.HasError() {
+			return ""
+		}
+	}
+	socket := defaultUnixSocket
+	if !bootstrap.UnixSocket.IsNull() {
+		socket = bootstrap.UnixSocket.ValueString()
+	}
+	client := p.grpcClient(socket, transportUnix, "", nil, "", dialer, interceptors, diags)
+	if diags.HasError() {
+		return ""
+	}
+
+	createCtx, cancel := context.WithTimeout(ctx, bootstrapApiKeyTimeout)
+	defer cancel()
+	response, err := client.CreateApiKey(createCtx, &v1.CreateApiKeyRequest{
+		Expiration: timestamppb.New(time.Now().Add(ttl)),
+	})
+	if err != nil {
+		diags.AddAttributeError(
+			path.Root("bootstrap"),
+			"Bootstrap Error",
+			fmt.Sprintf("Unable to create api key over unix socket %s, got error: %s", socket, err),
+		)
+		return ""
+	}
+	apiKey := response.GetApiKey()
+	if err := writeSecretFile(outputFile, apiKey); err != nil {
+		prefix, _, _ := strings.Cut(apiKey, ".")
+		diags.AddAttributeError(
+			path.Root("bootstrap").AtName("output_file"),
+			"Bootstrap Error",
+			fmt.Sprintf(
+				"Api key is created but not saved to %s, expire it with \"headscale apikeys expire --prefix %s\". Got error: %s",
+				outputFile,
+				prefix,
+				err,
+			),
+		)
+		return ""
+	}
+	tflog.Info(ctx, "headscale api key is bootstrapped", map[string]interface{}{
+		"output_file": outputFile,
+	})
+	return apiKey
+}
+
+// writeSecretFile writes content to file with mode 0600, file is replaced atomically.
+func writeSecretFile(name string, content string) error {
+	dir := filepath.Dir(name)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(dir, "."+filepath.Base(name)+"-")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(file.Name()) }()
+	if _, err := file.WriteString(content + "\n"); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), name)
+}
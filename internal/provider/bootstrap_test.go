@@ -0,0 +1,153 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestBootstrapApiKey(t *testing.T) {
+	testCases := []struct {
+		name string
+		// outputFile returns output file in temporary directory
+		outputFile func(t *testing.T, dir string) string
+		apiKey     string
+		calls      int64
+		error      string
+	}{
+		{
+			name: "existing output file",
+			outputFile: func(t *testing.T, dir string) string {
+				name := filepath.Join(dir, "api_key")
+				if err := os.WriteFile(name, []byte("existing.secret\n"), 0o600); err != nil {
+					t.Fatalf("cant write output file: %s", err)
+				}
+				return name
+			},
+			apiKey: "existing.secret",
+		},
+		{
+			name: "empty output file",
+			outputFile: func(t *testing.T, dir string) string {
+				name := filepath.Join(dir, "api_key")
+				if err := os.WriteFile(name, []byte("\n"), 0o600); err != nil {
+					t.Fatalf("cant write output file: %s", err)
+				}
+				return name
+			},
+			apiKey: "bootstrap.secret",
+			calls:  1,
+		},
+		{
+			name: "missing output file",
+			outputFile: func(t *testing.T, dir string) string {
+				return filepath.Join(dir, "secrets", "api_key")
+			},
+			apiKey: "bootstrap.secret",
+			calls:  1,
+		},
+		{
+			name: "output file that can not be written",
+			outputFile: func(t *testing.T, dir string) string {
+				// directory of output file is dangling symlink, so file is not found and can not be created
+				link := filepath.Join(dir, "secrets")
+				if err := os.Symlink(filepath.Join(dir, "missing"), link); err != nil {
+					t.Fatalf("cant create symlink: %s", err)
+				}
+				return filepath.Join(link, "api_key")
+			},
+			calls: 1,
+			error: `Api key is created but not saved to`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			server, socket := newBootstrapServer(t)
+			outputFile := testCase.outputFile(t, t.TempDir())
+			var diags diag.Diagnostics
+			apiKey := (&HeadscaleProvider{}).bootstrapApiKey(
+				context.Background(),
+				&BootstrapModel{UnixSocket: types.StringValue(socket), OutputFile: types.StringValue(outputFile)},
+				"",
+				false,
+				nil,
+				&diags,
+			)
+
+			if server.calls.Load() != testCase.calls {
+				t.Errorf("expected %d created api keys, got %d", testCase.calls, server.calls.Load())
+			}
+			if testCase.error != "" {
+				if !diags.HasError() {
+					t.Fatalf("expected error, got api key %q", apiKey)
+				}
+				detail := diags.Errors()[0].Detail()
+				// prefix of created key is kept to expire it
+				if !strings.Contains(detail, testCase.error) || !strings.Contains(detail, `"headscale apikeys expire --prefix bootstrap"`) {
+					t.Errorf("unexpected error: %s", detail)
+				}
+				return
+			}
+			if diags.HasError() {
+				t.Fatalf("unexpected errors: %v", diags)
+			}
+			if apiKey != testCase.apiKey {
+				t.Errorf("expected api key %q, got %q", testCase.apiKey, apiKey)
+			}
+			data, err := os.ReadFile(outputFile)
+			if err != nil || strings.TrimSpace(string(data)) != testCase.apiKey {
+				t.Errorf("expected output file with api key %q, got %q and error %v", testCase.apiKey, data, err)
+			}
+		})
+	}
+}
+
+func TestWriteSecretFile(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "api_key")
+	if err := os.WriteFile(name, []byte("old.secret\n"), 0o644); err != nil {
+		t.Fatalf("cant write file: %s", err)
+	}
+	old, err := os.Open(name)
+	if err != nil {
+		t.Fatalf("cant open file: %s", err)
+	}
+	defer old.Close()
+
+	if err := writeSecretFile(name, "new.secret"); err != nil {
+		t.Fatalf("cant write secret file: %s", err)
+	}
+
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatalf("cant stat file: %s", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("expected mode 0600, got %o", info.Mode().Perm())
+	}
+	data, err := os.ReadFile(name)
+	if err != nil || string(data) != "new.secret\n" {
+		t.Errorf("unexpected content %q, error %v", data, err)
+	}
+	// file is replaced by rename, so opened file keeps old content and readers never see partial key
+	oldInfo, err := old.Stat()
+	if err != nil {
+		t.Fatalf("cant stat old file: %s", err)
+	}
+	if os.SameFile(info, oldInfo) {
+		t.Errorf("expected file to be replaced, it is rewritten in place")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("expected no temporary files, got %v and error %v", entries, err)
+	}
+}
@@ -0,0 +1,311 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/setvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/listplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/setplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/paragor/terraform-provider-headscale/internal/headscaleclient"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &DebugNodeResource{}
+var _ resource.ResourceWithModifyPlan = &DebugNodeResource{}
+
+// debugNodeKeyLength is length of headscale registration id.
+const debugNodeKeyLength = 24
+
+func NewDebugNodeResource() resource.Resource {
+	return &DebugNodeResource{}
+}
+
+// DebugNodeResource defines the resource implementation.
+type DebugNodeResource struct {
+	client  headscaleclient.Client
+	cache   *snapshotCache
+	enabled bool
+}
+
+type DebugNodeResourceModel struct {
+	Id     types.Int64  `tfsdk:"id"`
+	User   types.String `tfsdk:"user"`
+	Name   types.String `tfsdk:"name"`
+	Key    types.String `tfsdk:"key"`
+	Routes types.Set    `tfsdk:"routes"`
+
+	UserId      types.Int64  `tfsdk:"user_id"`
+	GivenName   types.String `tfsdk:"given_name"`
+	IpAddresses types.List   `tfsdk:"ip_addresses"`
+}
+
+func (r *DebugNodeResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_debug_node"
+}
+
+func (r *DebugNodeResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		// This description is used by the documentation generator and the language server.
+		MarkdownDescription: "The resource creates fake node via headscale debug api and registers it, like `headscale debug create-node` and `headscale nodes register`. " +
+			"It is useful for tests of modules and policies without real tailscale clients. " +
+			"The resource requires provider's `enable_debug_resources = true`. Destroying the resource deletes the node.",
+
+		Attributes: map[string]schema.Attribute{
+			"id": schema.Int64Attribute{
+				Computed:            true,
+				MarkdownDescription: "ID of node",
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.UseStateForUnknown(),
+				},
+			},
+			"user": schema.StringAttribute{
+				Required:    true,
+				Description: "The name of the user who owns the node.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"name": schema.StringAttribute{
+				Required:    true,
+				Description: "The node's hostname.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"key": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Sensitive:   true,
+				Description: fmt.Sprintf("The registration key of the node, %d characters. Generated if it is not set.", debugNodeKeyLength),
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+					stringplanmodifier.RequiresReplace(),
+				},
+				Validators: []validator.String{
+					stringvalidator.LengthBetween(debugNodeKeyLength, debugNodeKeyLength),
+				},
+			},
+			"routes": schema.SetAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: `Advertised routes of the node. e.g. "10.0.0.0/8" or "192.168.0.0/24"`,
+				PlanModifiers: []planmodifier.Set{
+					setplanmodifier.RequiresReplace(),
+				},
+				Validators: []validator.Set{
+					setvalidator.ValueStringsAre(routeValidator()),
+				},
+			},
+			"user_id": schema.Int64Attribute{
+				Computed:    true,
+				Description: "The ID of the user who owns the node.",
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.UseStateForUnknown(),
+				},
+			},
+			"given_name": schema.StringAttribute{
+				Computed:    true,
+				Description: "The node's name in MagicDNS.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"ip_addresses": schema.ListAttribute{
+				Computed:    true,
+				ElementType: types.StringType,
+				Description: "The node's tailnet IP addresses.",
+				PlanModifiers: []planmodifier.List{
+					listplanmodifier.UseStateForUnknown(),
+				},
+			},
+		},
+	}
+}
+
+func (r *DebugNodeResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	config, ok := req.ProviderData.(*HeadscaleProviderConfiguration)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Resource Configure Type",
+			fmt.Sprintf("Expected *HeadscaleProviderConfiguration, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+
+		return
+	}
+
+	r.client = config.client
+	r.cache = config.cache
+	r.enabled = config.enableDebugResources
+}
+
+func (r *DebugNodeResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	// provider is not configured yet or resource is destroyed
+	if r.client == nil || req.Plan.Raw.IsNull() {
+		return
+	}
+	r.checkEnabled(&resp.Diagnostics)
+}
+
+func (r *DebugNodeResource) checkEnabled(diags *diag.Diagnostics) {
+	if !r.enabled {
+		diags.AddError(
+			"Debug resources are disabled",
+			"resource headscale_debug_node creates fake nodes, set provider's 'enable_debug_resources = true' to use it",
+		)
+	}
+}
+
+func (r *DebugNodeResource) readComputedFields(
+	ctx context.Context,
+	node *v1.Node,
+	data *DebugNodeResourceModel,
+) diag.Diagnostics {
+	data.Id = types.Int64Value(int64(node.GetId()))
+	data.UserId = types.Int64Value(int64(node.GetUser().GetId()))
+	data.GivenName = types.StringValue(node.GetGivenName())
+	ipAddresses := node.GetIpAddresses()
+	if ipAddresses == nil {
+		ipAddresses = make([]string, 0)
+	}
+	list, diags := types.ListValueFrom(ctx, types.StringType, ipAddresses)
+	data.IpAddresses = list
+	return diags
+}
+
+func (r *DebugNodeResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_debug_node", "create")
+	defer endOperation(&resp.Diagnostics)
+	var data DebugNodeResourceModel
+
+	// Read Terraform plan data into the model
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	r.checkEnabled(&resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if data.Key.IsUnknown() || data.Key.IsNull() {
+		key, err := newDebugNodeKey()
+		if err != nil {
+			resp.Diagnostics.AddError("Key Error", fmt.Sprintf("Unable to generate key, got error: %s", err))
+			return
+		}
+		data.Key = types.StringValue(key)
+	}
+	routes := []string{}
+	resp.Diagnostics.Append(data.Routes.ElementsAs(ctx, &routes, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	_, err := r.client.DebugCreateNode(ctx, &v1.DebugCreateNodeRequest{
+		User:   data.User.ValueString(),
+		Key:    data.Key.ValueString(),
+		Name:   data.Name.ValueString(),
+		Routes: routes,
+	})
+	if err != nil {
+		addClientError(&resp.Diagnostics, "create debug node", err)
+		return
+	}
+	response, err := r.client.RegisterNode(ctx, &v1.RegisterNodeRequest{
+		User: data.User.ValueString(),
+		Key:  data.Key.ValueString(),
+	})
+	r.cache.InvalidateNodes()
+	if err != nil {
+		addClientError(&resp.Diagnostics, "register debug node", err)
+		return
+	}
+	resp.Diagnostics.Append(r.readComputedFields(ctx, response.GetNode(), &data)...)
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *DebugNodeResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_debug_node", "read")
+	defer endOperation(&resp.Diagnostics)
+	var data DebugNodeResourceModel
+
+	// Read Terraform prior state data into the model
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	node, err := r.cache.Node(ctx, uint64(data.Id.ValueInt64()))
+	if err != nil {
+		addClientError(&resp.Diagnostics, "list nodes", err)
+		return
+	}
+	if node == nil {
+		removeNotFoundResource(ctx, &resp.State, &resp.Diagnostics, fmt.Sprintf("node %d", data.Id.ValueInt64()))
+		return
+	}
+	resp.Diagnostics.Append(r.readComputedFields(ctx, node, &data)...)
+
+	// Save updated data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *DebugNodeResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	resp.Diagnostics.AddError(
+		"Error updating debug node",
+		"debug nodes cannot be updated",
+	)
+}
+
+func (r *DebugNodeResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_debug_node", "delete")
+	defer endOperation(&resp.Diagnostics)
+	var data DebugNodeResourceModel
+
+	// Read Terraform prior state data into the model
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	_, err := r.client.DeleteNode(ctx, &v1.DeleteNodeRequest{
+		NodeId: uint64(data.Id.ValueInt64()),
+	})
+	r.cache.InvalidateNodes()
+	if err != nil && !headscaleclient.IsNotFound(err) {
+		addClientError(&resp.Diagnostics, "delete debug node", err)
+		return
+	}
+}
+
+// newDebugNodeKey returns random registration id like headscale generates.
+func newDebugNodeKey() (string, error) {
+	buf := make([]byte, debugNodeKeyLength*3/4)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
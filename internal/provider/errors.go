@@ -0,0 +1,243 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/paragor/terraform-provider-headscale/internal/headscaleclient"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// clientError is failed headscale api call explained for user.
+type clientError struct {
+	summary string
+	// hint tells how to fix error, it is empty if there is nothing to suggest.
+	hint string
+	// providerAttribute is attribute of provider configuration that causes error, for example "api_key".
+	providerAttribute string
+	// rejected is true if headscale rejected values of request, so error belongs to attribute of resource.
+	rejected bool
+}
+
+// tlsErrors are messages of tls handshake failures, grpc and rest clients return them only as text.
+var tlsErrors = []struct {
+	message string
+	error   clientError
+}{
+	{
+		message: "certificate signed by unknown authority",
+		error: clientError{
+			summary:           "Untrusted Server Certificate",
+			hint:              "Server certificate is not trusted, set 'ca_pem' of provider's 'tls' or env \"HEADSCALE_TLS_CA_PATH\" to CA of headscale certificate.",
+			providerAttribute: "tls",
+		},
+	},
+	{
+		message: "certificate is not trusted",
+		error: clientError{
+			summary:           "Untrusted Server Certificate",
+			hint:              "Server certificate is not trusted, set 'ca_pem' of provider's 'tls' or env \"HEADSCALE_TLS_CA_PATH\" to CA of headscale certificate.",
+			providerAttribute: "tls",
+		},
+	},
+	{
+		message: "certificate is valid for",
+		error: clientError{
+			summary:           "Server Certificate Name Mismatch",
+			hint:              "Server certificate does not match host of endpoint, use endpoint with the name from certificate.",
+			providerAttribute: "endpoint",
+		},
+	},
+	{
+		message: "certificate has expired or is not yet valid",
+		error: clientError{
+			summary:           "Expired Server Certificate",
+			hint:              "Server certificate is expired or is not valid yet, renew certificate of headscale or check clock of this host.",
+			providerAttribute: "endpoint",
+		},
+	},
+	{
+		message: "first record does not look like a TLS handshake",
+		error: clientError{
+			summary:           "Endpoint Does Not Serve TLS",
+			hint:              "Endpoint answered without tls, check port of endpoint or use transport \"plaintext\" with 'allow_insecure_plaintext'.",
+			providerAttribute: "transport",
+		},
+	},
+	{
+		message: "server gave HTTP response to HTTPS client",
+		error: clientError{
+			summary:           "Endpoint Does Not Serve TLS",
+			hint:              "Endpoint answered without tls, check port of endpoint or use transport \"plaintext\" with 'allow_insecure_plaintext'.",
+			providerAttribute: "transport",
+		},
+	},
+	{
+		message: "tls: certificate required",
+		error: clientError{
+			summary:           "Client Certificate Required",
+			hint:              "Server requires client certificate, set 'client_cert_pem' and 'client_key_pem' of provider's 'tls'.",
+			providerAttribute: "tls",
+		},
+	},
+	{
+		message: "tls: bad certificate",
+		error: clientError{
+			summary:           "Client Certificate Rejected",
+			hint:              "Server rejected client certificate, check 'client_cert_pem' and 'client_key_pem' of provider's 'tls'.",
+			providerAttribute: "tls",
+		},
+	},
+}
+
+// explainClientError translates error of headscale api call by its grpc status code, message and details.
+func explainClientError(err error) clientError {
+	if errors.Is(err, headscaleclient.ErrUnsupportedOperation) {
+		return clientError{summary: "Unsupported Headscale Server"}
+	}
+	if errors.Is(err, headscaleclient.ErrReadOnly) {
+		return clientError{
+			summary:           "Read Only Provider",
+			hint:              "Provider is configured with 'read_only = true' or env \"HEADSCALE_READ_ONLY\", it refuses calls that change headscale.",
+			providerAttribute: "read_only",
+		}
+	}
+	st := status.Convert(err)
+	for _, tlsError := range tlsErrors {
+		if strings.Contains(st.Message(), tlsError.message) {
+			return tlsError.error
+		}
+	}
+
+	var explained clientError
+	switch st.Code() {
+	case codes.Unauthenticated:
+		// headscale answers "invalid token" for unknown, expired and malformed keys alike
+		explained = clientError{
+			summary:           "Headscale Authentication Error",
+			hint:              "Api key is rejected by headscale, it is expired, deleted or mistyped. Check it with \"headscale apikeys list\", create new key with \"headscale apikeys create\" and set it to provider's 'api_key' or env \"HEADSCALE_API_KEY\".",
+			providerAttribute: "api_key",
+		}
+	case codes.PermissionDenied:
+		explained = clientError{
+			summary:  "Headscale Permission Denied",
+			hint:     "Request is rejected by headscale policy, check \"tagOwners\" and \"autoApprovers\" of the policy.",
+			rejected: true,
+		}
+	case codes.FailedPrecondition:
+		explained = clientError{summary: "Headscale Precondition Failed", rejected: true}
+		// node routes check planned routes against routes of node before they are approved
+		if strings.Contains(st.Message(), headscaleclient.RouteNotAdvertisedMessage) {
+			explained.summary = "Route Not Advertised"
+			explained.hint = "Route is not advertised by node, advertise it on the node with \"tailscale set --advertise-routes\" first."
+		}
+	case codes.InvalidArgument, codes.AlreadyExists, codes.OutOfRange:
+		explained = clientError{summary: "Headscale Rejected Request", rejected: true}
+	case codes.Unavailable:
+		explained = clientError{
+			summary:           "Headscale Unavailable",
+			hint:              "Headscale is unreachable, check 'endpoint', 'transport', 'proxy_url' and 'ssh_tunnel' of provider.",
+			providerAttribute: "endpoint",
+		}
+	case codes.DeadlineExceeded:
+		explained = clientError{
+			summary: "Headscale Timeout",
+			hint:    "Headscale did not answer in time, check its load and network between terraform and headscale.",
+		}
+	case codes.Unimplemented:
+		explained = clientError{
+			summary:           "Unsupported Headscale Server",
+			hint:              "Headscale does not implement this api, check 'server_version' of provider and version of headscale.",
+			providerAttribute: "server_version",
+		}
+	default:
+		explained = clientError{summary: "Client Error"}
+	}
+
+	for _, detail := range st.Details() {
+		switch detail := detail.(type) {
+		case *errdetails.BadRequest:
+			for _, violation := range detail.GetFieldViolations() {
+				explained.hint = joinHint(explained.hint, fmt.Sprintf("Field %q: %s.", violation.GetField(), violation.GetDescription()))
+			}
+		case *errdetails.PreconditionFailure:
+			for _, violation := range detail.GetViolations() {
+				explained.hint = joinHint(explained.hint, violation.GetDescription())
+			}
+		case *errdetails.LocalizedMessage:
+			explained.hint = joinHint(explained.hint, detail.GetMessage())
+		case *errdetails.Help:
+			for _, link := range detail.GetLinks() {
+				explained.hint = joinHint(explained.hint, fmt.Sprintf("%s: %s", link.GetDescription(), link.GetUrl()))
+			}
+		}
+	}
+	return explained
+}
+
+func joinHint(hint string, addition string) string {
+	if hint == "" {
+		return addition
+	}
+	return hint + "\n" + addition
+}
+
+func (e clientError) detail(action string, err error) string {
+	detail := fmt.Sprintf("Unable to %s, got error: %s", action, err)
+	if e.hint != "" {
+		detail += "\n\n" + e.hint
+	}
+	return detail
+}
+
+// addClientError adds diagnostic for failed headscale api call, action is for example "set node tags".
+func addClientError(diags *diag.Diagnostics, action string, err error) {
+	explained := explainClientError(err)
+	diags.AddError(explained.summary, explained.detail(action, err))
+}
+
+// addClientAttributeError adds diagnostic for failed headscale api call,
+// it is attached to attributePath of resource if headscale rejected its value, for example tags that are not allowed.
+func addClientAttributeError(diags *diag.Diagnostics, attributePath path.Path, action string, err error) {
+	explained := explainClientError(err)
+	if explained.rejected {
+		diags.AddAttributeError(attributePath, explained.summary, explained.detail(action, err))
+		return
+	}
+	diags.AddError(explained.summary, explained.detail(action, err))
+}
+
+// addProviderClientWarning adds warning for failed headscale api call of provider configuration,
+// it is attached to provider attribute that causes error.
+func addProviderClientWarning(diags *diag.Diagnostics, summary string, detail string, err error) {
+	explained := explainClientError(err)
+	if explained.hint != "" {
+		detail += "\n\n" + explained.hint
+	}
+	if explained.providerAttribute != "" {
+		diags.AddAttributeWarning(path.Root(explained.providerAttribute), summary, detail)
+		return
+	}
+	diags.AddWarning(summary, detail)
+}
+
+// removeNotFoundResource removes resource from state with warning instead of failing refresh,
+// next plan shows resource as new, objects of some resources, for example nodes, can not be created again.
+// description is for example "node 42".
+func removeNotFoundResource(ctx context.Context, state *tfsdk.State, diags *diag.Diagnostics, description string) {
+	diags.AddWarning(
+		"Resource not found",
+		fmt.Sprintf("%s is not found on headscale server, it is removed from state, the next plan will show it as new", description),
+	)
+	state.RemoveResource(ctx)
+}
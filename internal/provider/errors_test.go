@@ -0,0 +1,289 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/paragor/terraform-provider-headscale/internal/headscaleclient"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// emptyHeadscale returns provider configuration of headscale server without objects.
+// ListPreAuthKeys fails like headscale does for deleted user.
+func emptyHeadscale() *HeadscaleProviderConfiguration {
+	client := &fakeClient{
+		listNodes: func(ctx context.Context, _ *v1.ListNodesRequest) (*v1.ListNodesResponse, error) {
+			return &v1.ListNodesResponse{}, nil
+		},
+		listUsers: func(ctx context.Context, _ *v1.ListUsersRequest) (*v1.ListUsersResponse, error) {
+			return &v1.ListUsersResponse{}, nil
+		},
+		listApiKeys: func(ctx context.Context, _ *v1.ListApiKeysRequest) (*v1.ListApiKeysResponse, error) {
+			return &v1.ListApiKeysResponse{}, nil
+		},
+		listPreAuthKeys: func(ctx context.Context, in *v1.ListPreAuthKeysRequest) (*v1.ListPreAuthKeysResponse, error) {
+			if in.GetUser() != 1 {
+				return nil, status.Error(codes.Unknown, "user not found")
+			}
+			return &v1.ListPreAuthKeysResponse{}, nil
+		},
+	}
+	api := headscaleclient.DefaultServerAPI()
+	return &HeadscaleProviderConfiguration{
+		client:               client,
+		serverAPI:            api,
+		nodeRoutes:           headscaleclient.NewNodeRoutes(client, api),
+		nodeTags:             headscaleclient.NewNodeTags(client, api),
+		cache:                newSnapshotCache(client),
+		enableDebugResources: true,
+	}
+}
+
+// stateOf returns state of resource with null attributes except given ones.
+func stateOf(t *testing.T, r resource.Resource, attributes map[string]attr.Value) tfsdk.State {
+	t.Helper()
+	ctx := context.Background()
+	schemaResp := &resource.SchemaResponse{}
+	r.Schema(ctx, resource.SchemaRequest{}, schemaResp)
+	if schemaResp.Diagnostics.HasError() {
+		t.Fatalf("unexpected schema errors: %v", schemaResp.Diagnostics)
+	}
+	objectType := schemaResp.Schema.Type().TerraformType(ctx).(tftypes.Object)
+	values := make(map[string]tftypes.Value, len(objectType.AttributeTypes))
+	for name, attributeType := range objectType.AttributeTypes {
+		values[name] = tftypes.NewValue(attributeType, nil)
+	}
+	state := tfsdk.State{Schema: schemaResp.Schema, Raw: tftypes.NewValue(objectType, values)}
+	for name, value := range attributes {
+		if diags := state.SetAttribute(ctx, path.Root(name), value); diags.HasError() {
+			t.Fatalf("cant set attribute %s: %v", name, diags)
+		}
+	}
+	return state
+}
+
+func TestReadRemovesNotFoundResource(t *testing.T) {
+	testCases := []struct {
+		name        string
+		resource    resource.Resource
+		attributes  map[string]attr.Value
+		description string
+	}{
+		{
+			name:        "user",
+			resource:    NewUserResource(),
+			attributes:  map[string]attr.Value{"id": types.Int64Value(7)},
+			description: "user 7",
+		},
+		{
+			name:        "api key",
+			resource:    NewApiKeyResource(),
+			attributes:  map[string]attr.Value{"id": types.StringValue("abcdefg")},
+			description: `api key "abcdefg"`,
+		},
+		{
+			name:        "pre auth key",
+			resource:    NewPreAuthKeyResource(),
+			attributes:  map[string]attr.Value{"id": types.Int64Value(3), "user_id": types.Int64Value(1)},
+			description: "pre auth key 3",
+		},
+		{
+			name:        "pre auth key of deleted user",
+			resource:    NewPreAuthKeyResource(),
+			attributes:  map[string]attr.Value{"id": types.Int64Value(3), "user_id": types.Int64Value(2)},
+			description: "user 2 of pre auth key",
+		},
+		{
+			name:        "node tags",
+			resource:    NewNodeTagsResource(),
+			attributes:  map[string]attr.Value{"id": types.Int64Value(5), "node_id": types.Int64Value(5)},
+			description: "node 5",
+		},
+		{
+			name:        "node routes",
+			resource:    NewNodeRoutesResource(),
+			attributes:  map[string]attr.Value{"id": types.Int64Value(5), "node_id": types.Int64Value(5)},
+			description: "node 5",
+		},
+		{
+			name:        "node registration",
+			resource:    NewNodeRegistrationResource(),
+			attributes:  map[string]attr.Value{"id": types.Int64Value(5)},
+			description: "node 5",
+		},
+		{
+			name:        "debug node",
+			resource:    NewDebugNodeResource(),
+			attributes:  map[string]attr.Value{"id": types.Int64Value(5)},
+			description: "node 5",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctx := context.Background()
+			configureResp := &resource.ConfigureResponse{}
+			testCase.resource.(resource.ResourceWithConfigure).Configure(
+				ctx,
+				resource.ConfigureRequest{ProviderData: emptyHeadscale()},
+				configureResp,
+			)
+			if configureResp.Diagnostics.HasError() {
+				t.Fatalf("unexpected configure errors: %v", configureResp.Diagnostics)
+			}
+
+			state := stateOf(t, testCase.resource, testCase.attributes)
+			resp := &resource.ReadResponse{State: state}
+			testCase.resource.Read(ctx, resource.ReadRequest{State: state}, resp)
+
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("unexpected errors: %v", resp.Diagnostics)
+			}
+			warnings := resp.Diagnostics.Warnings()
+			if len(warnings) != 1 || warnings[0].Summary() != "Resource not found" ||
+				!strings.HasPrefix(warnings[0].Detail(), testCase.description+" is not found") ||
+				strings.Contains(warnings[0].Detail(), "created") {
+				t.Errorf("expected not found warning of %s, got %v", testCase.description, resp.Diagnostics)
+			}
+			if !resp.State.Raw.IsNull() {
+				t.Errorf("expected resource to be removed from state, got %s", resp.State.Raw)
+			}
+		})
+	}
+}
+
+func TestExplainClientErrorOfTLS(t *testing.T) {
+	for _, tlsError := range tlsErrors {
+		t.Run(tlsError.message, func(t *testing.T) {
+			err := status.Errorf(codes.Unavailable, `connection error: desc = "transport: authentication handshake failed: tls: %s"`, tlsError.message)
+			if actual := explainClientError(err); actual != tlsError.error {
+				t.Errorf("expected %+v, got %+v", tlsError.error, actual)
+			}
+		})
+	}
+}
+
+func TestExplainClientError(t *testing.T) {
+	testCases := []struct {
+		name              string
+		err               error
+		summary           string
+		hint              string
+		providerAttribute string
+		rejected          bool
+	}{
+		// messages of codes are the ones headscale v0.26 returns
+		{name: "OK", err: status.Error(codes.OK, ""), summary: "Client Error"},
+		{name: "Canceled", err: status.Error(codes.Canceled, "context canceled"), summary: "Client Error"},
+		{name: "Unknown", err: status.Error(codes.Unknown, "unknown"), summary: "Client Error"},
+		{
+			name:     "InvalidArgument",
+			err:      status.Error(codes.InvalidArgument, "parsing route: netip.ParsePrefix(\"10.0.0.0\"): no '/'"),
+			summary:  "Headscale Rejected Request",
+			rejected: true,
+		},
+		{
+			name:    "DeadlineExceeded",
+			err:     status.Error(codes.DeadlineExceeded, "context deadline exceeded"),
+			summary: "Headscale Timeout",
+			hint:    "Headscale did not answer in time",
+		},
+		{name: "NotFound", err: status.Error(codes.NotFound, "record not found"), summary: "Client Error"},
+		{
+			name:     "AlreadyExists",
+			err:      status.Error(codes.AlreadyExists, "user already exists"),
+			summary:  "Headscale Rejected Request",
+			rejected: true,
+		},
+		{
+			name:     "PermissionDenied",
+			err:      status.Error(codes.PermissionDenied, "tag not allowed"),
+			summary:  "Headscale Permission Denied",
+			hint:     "tagOwners",
+			rejected: true,
+		},
+		{name: "ResourceExhausted", err: status.Error(codes.ResourceExhausted, "too many requests"), summary: "Client Error"},
+		{
+			name:     "FailedPrecondition",
+			err:      status.Error(codes.FailedPrecondition, "precondition"),
+			summary:  "Headscale Precondition Failed",
+			rejected: true,
+		},
+		{name: "Aborted", err: status.Error(codes.Aborted, "aborted"), summary: "Client Error"},
+		{
+			name:     "OutOfRange",
+			err:      status.Error(codes.OutOfRange, "out of range"),
+			summary:  "Headscale Rejected Request",
+			rejected: true,
+		},
+		{
+			name:              "Unimplemented",
+			err:               status.Error(codes.Unimplemented, "unknown method SetApprovedRoutes"),
+			summary:           "Unsupported Headscale Server",
+			hint:              "server_version",
+			providerAttribute: "server_version",
+		},
+		{name: "Internal", err: status.Error(codes.Internal, "internal"), summary: "Client Error"},
+		{
+			name:              "Unavailable",
+			err:               status.Error(codes.Unavailable, "connection refused"),
+			summary:           "Headscale Unavailable",
+			hint:              "Headscale is unreachable",
+			providerAttribute: "endpoint",
+		},
+		{name: "DataLoss", err: status.Error(codes.DataLoss, "data loss"), summary: "Client Error"},
+		{
+			name:              "Unauthenticated",
+			err:               status.Error(codes.Unauthenticated, "invalid token"),
+			summary:           "Headscale Authentication Error",
+			hint:              "headscale apikeys create",
+			providerAttribute: "api_key",
+		},
+		{
+			name:     "route is not advertised",
+			err:      status.Error(codes.FailedPrecondition, "route 10.0.0.0/24 is not advertised by node 5"),
+			summary:  "Route Not Advertised",
+			hint:     "tailscale set --advertise-routes",
+			rejected: true,
+		},
+		{
+			name:              "read only",
+			err:               headscaleclient.ErrReadOnly,
+			summary:           "Read Only Provider",
+			hint:              "read_only = true",
+			providerAttribute: "read_only",
+		},
+		{
+			name:    "unsupported operation",
+			err:     headscaleclient.ErrUnsupportedOperation,
+			summary: "Unsupported Headscale Server",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual := explainClientError(testCase.err)
+			if actual.summary != testCase.summary {
+				t.Errorf("expected summary %q, got %q", testCase.summary, actual.summary)
+			}
+			if !strings.Contains(actual.hint, testCase.hint) || (testCase.hint == "") != (actual.hint == "") {
+				t.Errorf("expected hint with %q, got %q", testCase.hint, actual.hint)
+			}
+			if actual.providerAttribute != testCase.providerAttribute || actual.rejected != testCase.rejected {
+				t.Errorf("expected attribute %q and rejected %t, got %q and %t", testCase.providerAttribute, testCase.rejected, actual.providerAttribute, actual.rejected)
+			}
+		})
+	}
+}
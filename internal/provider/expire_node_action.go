@@ -0,0 +1,105 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/action"
+	"github.com/hashicorp/terraform-plugin-framework/action/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/paragor/terraform-provider-headscale/internal/headscaleclient"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ action.Action = &ExpireNodeAction{}
+var _ action.ActionWithConfigure = &ExpireNodeAction{}
+
+func NewExpireNodeAction() action.Action {
+	return &ExpireNodeAction{}
+}
+
+// ExpireNodeAction defines the action implementation.
+type ExpireNodeAction struct {
+	client headscaleclient.Client
+	cache  *snapshotCache
+}
+
+type ExpireNodeActionModel struct {
+	NodeId  types.Int64 `tfsdk:"node_id"`
+	Confirm types.Bool  `tfsdk:"confirm"`
+}
+
+func (a *ExpireNodeAction) Metadata(ctx context.Context, req action.MetadataRequest, resp *action.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_expire_node"
+}
+
+func (a *ExpireNodeAction) Schema(ctx context.Context, req action.SchemaRequest, resp *action.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		// This description is used by the documentation generator and the language server.
+		MarkdownDescription: "The action expires node, so the node must log in again, like `headscale nodes expire`.",
+
+		Attributes: map[string]schema.Attribute{
+			"node_id": schema.Int64Attribute{
+				Required:    true,
+				Description: "The ID of the node.",
+			},
+			"confirm": schema.BoolAttribute{
+				Required:            true,
+				MarkdownDescription: "Must be `true`, the action logs out the node.",
+				Validators: []validator.Bool{
+					confirmedValidator{},
+				},
+			},
+		},
+	}
+}
+
+func (a *ExpireNodeAction) Configure(ctx context.Context, req action.ConfigureRequest, resp *action.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	config, ok := req.ProviderData.(*HeadscaleProviderConfiguration)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Action Configure Type",
+			fmt.Sprintf("Expected *HeadscaleProviderConfiguration, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+
+		return
+	}
+
+	a.client = config.client
+	a.cache = config.cache
+}
+
+func (a *ExpireNodeAction) Invoke(ctx context.Context, req action.InvokeRequest, resp *action.InvokeResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_expire_node", "invoke")
+	defer endOperation(&resp.Diagnostics)
+	var data ExpireNodeActionModel
+
+	// Read Terraform config data into the model
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	response, err := a.client.ExpireNode(ctx, &v1.ExpireNodeRequest{
+		NodeId: uint64(data.NodeId.ValueInt64()),
+	})
+	a.cache.InvalidateNodes()
+	if err != nil {
+		addClientError(&resp.Diagnostics, "expire node", err)
+		return
+	}
+	resp.SendProgress(action.InvokeProgressEvent{
+		Message: fmt.Sprintf("node %d %q is expired", response.GetNode().GetId(), response.GetNode().GetGivenName()),
+	})
+}
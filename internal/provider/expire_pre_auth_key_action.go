@@ -0,0 +1,175 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/action"
+	"github.com/hashicorp/terraform-plugin-framework/action/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/paragor/terraform-provider-headscale/internal/headscaleclient"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ action.Action = &ExpirePreAuthKeyAction{}
+var _ action.ActionWithConfigure = &ExpirePreAuthKeyAction{}
+
+func NewExpirePreAuthKeyAction() action.Action {
+	return &ExpirePreAuthKeyAction{}
+}
+
+// ExpirePreAuthKeyAction defines the action implementation.
+type ExpirePreAuthKeyAction struct {
+	client headscaleclient.Client
+	cache  *snapshotCache
+}
+
+type ExpirePreAuthKeyActionModel struct {
+	UserId    types.Int64  `tfsdk:"user_id"`
+	User      types.String `tfsdk:"user"`
+	Id        types.Int64  `tfsdk:"id"`
+	KeyPrefix types.String `tfsdk:"key_prefix"`
+}
+
+func (a *ExpirePreAuthKeyAction) Metadata(ctx context.Context, req action.MetadataRequest, resp *action.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_expire_pre_auth_key"
+}
+
+func (a *ExpirePreAuthKeyAction) Schema(ctx context.Context, req action.SchemaRequest, resp *action.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		// This description is used by the documentation generator and the language server.
+		MarkdownDescription: "The action expires pre auth key, for example leaked key, like `headscale preauthkeys expire`. Nodes that are already registered with the key are not affected.",
+
+		Attributes: map[string]schema.Attribute{
+			"user_id": schema.Int64Attribute{
+				MarkdownDescription: "User Id. Exactly one of `user_id` or `user` must be set.",
+				Optional:            true,
+			},
+			"user": schema.StringAttribute{
+				MarkdownDescription: "User name. Exactly one of `user_id` or `user` must be set.",
+				Optional:            true,
+				Validators: []validator.String{
+					stringvalidator.ExactlyOneOf(path.MatchRoot("user_id")),
+				},
+			},
+			"id": schema.Int64Attribute{
+				MarkdownDescription: "Id of pre auth key, for example `headscale_pre_auth_key.ci.id`. Exactly one of `id` or `key_prefix` must be set.",
+				Optional:            true,
+			},
+			"key_prefix": schema.StringAttribute{
+				MarkdownDescription: "Beginning of pre auth key that matches exactly one key of user, so the whole secret is not written to configuration. Exactly one of `id` or `key_prefix` must be set.",
+				Optional:            true,
+				Validators: []validator.String{
+					stringvalidator.ExactlyOneOf(path.MatchRoot("id")),
+					stringvalidator.LengthAtLeast(1),
+				},
+			},
+		},
+	}
+}
+
+func (a *ExpirePreAuthKeyAction) Configure(ctx context.Context, req action.ConfigureRequest, resp *action.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	config, ok := req.ProviderData.(*HeadscaleProviderConfiguration)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Action Configure Type",
+			fmt.Sprintf("Expected *HeadscaleProviderConfiguration, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+
+		return
+	}
+
+	a.client = config.client
+	a.cache = config.cache
+}
+
+func (a *ExpirePreAuthKeyAction) Invoke(ctx context.Context, req action.InvokeRequest, resp *action.InvokeResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_expire_pre_auth_key", "invoke")
+	defer endOperation(&resp.Diagnostics)
+	var data ExpirePreAuthKeyActionModel
+
+	// Read Terraform config data into the model
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if data.UserId.IsNull() {
+		user, err := findUserByName(ctx, a.client, data.User.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("user"), "User Error", err.Error())
+			return
+		}
+		data.UserId = types.Int64Value(int64(user.GetId()))
+	}
+	keys, err := a.client.ListPreAuthKeys(ctx, &v1.ListPreAuthKeysRequest{
+		User: uint64(data.UserId.ValueInt64()),
+	})
+	if err != nil {
+		addClientError(&resp.Diagnostics, "list pre auth keys", err)
+		return
+	}
+	// headscale expires key by its secret, so it is taken from list of keys instead of configuration
+	key, keyPath, err := findPreAuthKey(keys.GetPreAuthKeys(), data)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(keyPath, "Pre Auth Key Error", err.Error())
+		return
+	}
+	_, err = a.client.ExpirePreAuthKey(ctx, &v1.ExpirePreAuthKeyRequest{
+		User: uint64(data.UserId.ValueInt64()),
+		Key:  key.GetKey(),
+	})
+	a.cache.InvalidatePreAuthKeys(uint64(data.UserId.ValueInt64()))
+	if err != nil {
+		addClientError(&resp.Diagnostics, "expire pre auth key", err)
+		return
+	}
+}
+
+// findPreAuthKey returns pre auth key by id or key prefix of action and path of attribute that selects it.
+func findPreAuthKey(keys []*v1.PreAuthKey, data ExpirePreAuthKeyActionModel) (*v1.PreAuthKey, path.Path, error) {
+	if !data.Id.IsNull() {
+		for _, key := range keys {
+			if key.GetId() == uint64(data.Id.ValueInt64()) {
+				return key, path.Root("id"), nil
+			}
+		}
+		return nil, path.Root("id"), fmt.Errorf("pre auth key %d of user %d is not found", data.Id.ValueInt64(), data.UserId.ValueInt64())
+	}
+
+	var matched []*v1.PreAuthKey
+	for _, key := range keys {
+		if strings.HasPrefix(key.GetKey(), data.KeyPrefix.ValueString()) {
+			matched = append(matched, key)
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return nil, path.Root("key_prefix"), fmt.Errorf("no pre auth key of user %d starts with key_prefix", data.UserId.ValueInt64())
+	case 1:
+		return matched[0], path.Root("key_prefix"), nil
+	default:
+		ids := make([]string, 0, len(matched))
+		for _, key := range matched {
+			ids = append(ids, fmt.Sprint(key.GetId()))
+		}
+		return nil, path.Root("key_prefix"), fmt.Errorf(
+			"key_prefix matches %d pre auth keys of user %d with ids %s, use longer prefix or id",
+			len(matched), data.UserId.ValueInt64(), strings.Join(ids, ", "),
+		)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+)
+
+func TestFindPreAuthKey(t *testing.T) {
+	keys := []*v1.PreAuthKey{
+		{Id: 1, Key: "0a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f6071"},
+		{Id: 2, Key: "0a1bffffffffffffffffffffffffffffffffffffffffffff"},
+		{Id: 3, Key: "9999999999999999999999999999999999999999999999ab"},
+	}
+	testCases := []struct {
+		name     string
+		id       types.Int64
+		prefix   types.String
+		expected uint64
+		path     path.Path
+		error    string
+	}{
+		{name: "by id", id: types.Int64Value(2), prefix: types.StringNull(), expected: 2, path: path.Root("id")},
+		{name: "unknown id", id: types.Int64Value(4), prefix: types.StringNull(), path: path.Root("id"), error: "not found"},
+		{name: "by prefix", id: types.Int64Null(), prefix: types.StringValue("0a1b2c"), expected: 1, path: path.Root("key_prefix")},
+		{name: "whole key", id: types.Int64Null(), prefix: types.StringValue(keys[2].Key), expected: 3, path: path.Root("key_prefix")},
+		{name: "ambiguous prefix", id: types.Int64Null(), prefix: types.StringValue("0a1b"), path: path.Root("key_prefix"), error: "ids 1, 2"},
+		{name: "unknown prefix", id: types.Int64Null(), prefix: types.StringValue("ffff"), path: path.Root("key_prefix"), error: "no pre auth key"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			key, keyPath, err := findPreAuthKey(keys, ExpirePreAuthKeyActionModel{
+				UserId:    types.Int64Value(7),
+				Id:        testCase.id,
+				KeyPrefix: testCase.prefix,
+			})
+			if !keyPath.Equal(testCase.path) {
+				t.Errorf("expected path %s, got %s", testCase.path, keyPath)
+			}
+			if testCase.error != "" {
+				if err == nil || !strings.Contains(err.Error(), testCase.error) {
+					t.Fatalf("expected error with %q, got key %v, error %v", testCase.error, key, err)
+				}
+				if strings.Contains(err.Error(), keys[0].Key) {
+					t.Errorf("error contains pre auth key: %s", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if key.GetId() != testCase.expected {
+				t.Errorf("expected key %d, got %d", testCase.expected, key.GetId())
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/paragor/terraform-provider-headscale/internal/headscaleclient"
+)
+
+// fakeClient is headscale client of tests, calls without func panic.
+type fakeClient struct {
+	headscaleclient.Client
+
+	listNodes       func(ctx context.Context, in *v1.ListNodesRequest) (*v1.ListNodesResponse, error)
+	listUsers       func(ctx context.Context, in *v1.ListUsersRequest) (*v1.ListUsersResponse, error)
+	listPreAuthKeys func(ctx context.Context, in *v1.ListPreAuthKeysRequest) (*v1.ListPreAuthKeysResponse, error)
+	listApiKeys     func(ctx context.Context, in *v1.ListApiKeysRequest) (*v1.ListApiKeysResponse, error)
+	setTags         func(ctx context.Context, in *v1.SetTagsRequest) (*v1.SetTagsResponse, error)
+	deleteNode      func(ctx context.Context, in *v1.DeleteNodeRequest) (*v1.DeleteNodeResponse, error)
+	expireNode      func(ctx context.Context, in *v1.ExpireNodeRequest) (*v1.ExpireNodeResponse, error)
+}
+
+func (c *fakeClient) ListNodes(ctx context.Context, in *v1.ListNodesRequest) (*v1.ListNodesResponse, error) {
+	return c.listNodes(ctx, in)
+}
+
+func (c *fakeClient) ListUsers(ctx context.Context, in *v1.ListUsersRequest) (*v1.ListUsersResponse, error) {
+	return c.listUsers(ctx, in)
+}
+
+func (c *fakeClient) ListPreAuthKeys(ctx context.Context, in *v1.ListPreAuthKeysRequest) (*v1.ListPreAuthKeysResponse, error) {
+	return c.listPreAuthKeys(ctx, in)
+}
+
+func (c *fakeClient) SetTags(ctx context.Context, in *v1.SetTagsRequest) (*v1.SetTagsResponse, error) {
+	return c.setTags(ctx, in)
+}
+
+func (c *fakeClient) ListApiKeys(ctx context.Context, in *v1.ListApiKeysRequest) (*v1.ListApiKeysResponse, error) {
+	return c.listApiKeys(ctx, in)
+}
+
+func (c *fakeClient) DeleteNode(ctx context.Context, in *v1.DeleteNodeRequest) (*v1.DeleteNodeResponse, error) {
+	return c.deleteNode(ctx, in)
+}
+
+func (c *fakeClient) ExpireNode(ctx context.Context, in *v1.ExpireNodeRequest) (*v1.ExpireNodeResponse, error) {
+	return c.expireNode(ctx, in)
+}
@@ -0,0 +1,81 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// headscaleCLIConfig is connection settings of headscale CLI,
+// so the same credentials work for both the CLI and terraform.
+// Values are taken from "cli" and "unix_socket" of headscale config file
+// and overridden by CLI envs "HEADSCALE_CLI_ADDRESS", "HEADSCALE_CLI_API_KEY" and "HEADSCALE_CLI_INSECURE".
+type headscaleCLIConfig struct {
+	// Address is remote grpc address, CLI uses tls for it.
+	Address string
+	APIKey  string
+	// Insecure disables verification of server certificate, it is nil if it is not set.
+	Insecure *bool
+	// UnixSocket is local socket of headscale, CLI uses it if Address is not set.
+	UnixSocket string
+}
+
+// headscaleConfigFile is part of headscale config.yaml that is used by CLI.
+type headscaleConfigFile struct {
+	UnixSocket string `yaml:"unix_socket"`
+	CLI        struct {
+		Address  string `yaml:"address"`
+		APIKey   string `yaml:"api_key"`
+		Insecure *bool  `yaml:"insecure"`
+	} `yaml:"cli"`
+}
+
+// loadHeadscaleCLIConfig reads headscale config file if path is not empty and applies CLI envs.
+func loadHeadscaleCLIConfig(path string) (headscaleCLIConfig, error) {
+	config := headscaleCLIConfig{}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return config, fmt.Errorf("cant read headscale config: %w", err)
+		}
+		file := headscaleConfigFile{}
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return config, fmt.Errorf("cant parse headscale config %s: %w", path, err)
+		}
+		config.Address = file.CLI.Address
+		config.APIKey = file.CLI.APIKey
+		config.Insecure = file.CLI.Insecure
+		config.UnixSocket = file.UnixSocket
+	}
+
+	if address := os.Getenv("HEADSCALE_CLI_ADDRESS"); address != "" {
+		config.Address = address
+	}
+	if apiKey := os.Getenv("HEADSCALE_CLI_API_KEY"); apiKey != "" {
+		config.APIKey = apiKey
+	}
+	if insecure := os.Getenv("HEADSCALE_CLI_INSECURE"); insecure != "" {
+		value, err := strconv.ParseBool(insecure)
+		if err != nil {
+			return config, fmt.Errorf("cant parse env HEADSCALE_CLI_INSECURE: %w", err)
+		}
+		config.Insecure = &value
+	}
+	return config, nil
+}
+
+// endpoint returns endpoint and transport that CLI uses: remote address over tls or local unix socket.
+func (c headscaleCLIConfig) endpoint() (endpoint string, transport string) {
+	if c.Address != "" {
+		return c.Address, transportTLS
+	}
+	if c.UnixSocket != "" {
+		return c.UnixSocket, transportUnix
+	}
+	return "", ""
+}
@@ -0,0 +1,49 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ function.Function = &IsTagFunction{}
+
+func NewIsTagFunction() function.Function {
+	return &IsTagFunction{}
+}
+
+// IsTagFunction defines the function implementation.
+type IsTagFunction struct{}
+
+func (f *IsTagFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "is_tag"
+}
+
+func (f *IsTagFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:             "Check tag",
+		MarkdownDescription: "Returns true if value is valid tag for `headscale_node_tags` and `acl_tags` of pre auth keys, for example `tag:server`.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:                "value",
+				MarkdownDescription: "Value to check.",
+			},
+		},
+		Return: function.BoolReturn{},
+	}
+}
+
+func (f *IsTagFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var value string
+
+	resp.Error = function.ConcatFuncErrors(req.Arguments.Get(ctx, &value))
+	if resp.Error != nil {
+		return
+	}
+
+	resp.Error = function.ConcatFuncErrors(resp.Result.Set(ctx, checkTag(value) == nil))
+}
@@ -0,0 +1,44 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestIsTagFunction(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected bool
+	}{
+		{value: "tag:server", expected: true},
+		{value: "tag:web-01", expected: true},
+		{value: "tag:snake_case", expected: true},
+		{value: "tag:", expected: false},
+		{value: "server", expected: false},
+		{value: "group:admins", expected: false},
+		{value: "xtag:server", expected: false},
+		{value: "tag:server ", expected: false},
+		{value: " tag:server", expected: false},
+		{value: "tag:server,tag:web", expected: false},
+		{value: "tag:a.b", expected: false},
+		{value: "prefix tag:server", expected: false},
+		{value: "", expected: false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.value, func(t *testing.T) {
+			result, err := runFunction(t, NewIsTagFunction(), []attr.Value{types.StringValue(testCase.value)}, types.BoolUnknown())
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !result.Equal(types.BoolValue(testCase.expected)) {
+				t.Errorf("expected %t, got %s", testCase.expected, result)
+			}
+		})
+	}
+}
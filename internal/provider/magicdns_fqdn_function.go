@@ -0,0 +1,67 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ function.Function = &MagicDNSFQDNFunction{}
+
+func NewMagicDNSFQDNFunction() function.Function {
+	return &MagicDNSFQDNFunction{}
+}
+
+// MagicDNSFQDNFunction defines the function implementation.
+type MagicDNSFQDNFunction struct{}
+
+func (f *MagicDNSFQDNFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "magicdns_fqdn"
+}
+
+func (f *MagicDNSFQDNFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:             "Build MagicDNS name of node",
+		MarkdownDescription: "Returns MagicDNS name of node like headscale builds it, for example `server.tailnet.example.com`. If `base_domain` is empty, returns `given_name`.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:                "given_name",
+				MarkdownDescription: "Given name of node, for example `given_name` of `headscale_node_registration`.",
+				Validators: []function.StringParameterValidator{
+					givenNameValidator(),
+				},
+			},
+			function.StringParameter{
+				Name:                "base_domain",
+				MarkdownDescription: "`dns.base_domain` of headscale config, for example `tailnet.example.com`.",
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+func (f *MagicDNSFQDNFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var givenName, baseDomain string
+
+	resp.Error = function.ConcatFuncErrors(req.Arguments.Get(ctx, &givenName, &baseDomain))
+	if resp.Error != nil {
+		return
+	}
+
+	fqdn := givenName
+	if baseDomain = strings.Trim(baseDomain, "."); baseDomain != "" {
+		fqdn = givenName + "." + baseDomain
+	}
+	if len(fqdn) > maxFQDNLength {
+		resp.Error = function.NewFuncError(fmt.Sprintf("MagicDNS name %q is longer than %d characters", fqdn, maxFQDNLength))
+		return
+	}
+
+	resp.Error = function.ConcatFuncErrors(resp.Result.Set(ctx, fqdn))
+}
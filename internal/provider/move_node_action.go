@@ -0,0 +1,129 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/action"
+	"github.com/hashicorp/terraform-plugin-framework/action/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/paragor/terraform-provider-headscale/internal/headscaleclient"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ action.Action = &MoveNodeAction{}
+var _ action.ActionWithConfigure = &MoveNodeAction{}
+
+func NewMoveNodeAction() action.Action {
+	return &MoveNodeAction{}
+}
+
+// MoveNodeAction defines the action implementation.
+type MoveNodeAction struct {
+	client headscaleclient.Client
+	cache  *snapshotCache
+}
+
+type MoveNodeActionModel struct {
+	NodeId  types.Int64  `tfsdk:"node_id"`
+	UserId  types.Int64  `tfsdk:"user_id"`
+	User    types.String `tfsdk:"user"`
+	Confirm types.Bool   `tfsdk:"confirm"`
+}
+
+func (a *MoveNodeAction) Metadata(ctx context.Context, req action.MetadataRequest, resp *action.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_move_node"
+}
+
+func (a *MoveNodeAction) Schema(ctx context.Context, req action.SchemaRequest, resp *action.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		// This description is used by the documentation generator and the language server.
+		MarkdownDescription: "The action moves node to another user, like `headscale nodes move`.",
+
+		Attributes: map[string]schema.Attribute{
+			"node_id": schema.Int64Attribute{
+				Required:    true,
+				Description: "The ID of the node.",
+			},
+			"user_id": schema.Int64Attribute{
+				MarkdownDescription: "ID of new owner of the node. Exactly one of `user_id` or `user` must be set.",
+				Optional:            true,
+			},
+			"user": schema.StringAttribute{
+				MarkdownDescription: "Name of new owner of the node. Exactly one of `user_id` or `user` must be set.",
+				Optional:            true,
+				Validators: []validator.String{
+					stringvalidator.ExactlyOneOf(path.MatchRoot("user_id")),
+				},
+			},
+			"confirm": schema.BoolAttribute{
+				Required:            true,
+				MarkdownDescription: "Must be `true`, the action changes owner of the node and access of the node by policy.",
+				Validators: []validator.Bool{
+					confirmedValidator{},
+				},
+			},
+		},
+	}
+}
+
+func (a *MoveNodeAction) Configure(ctx context.Context, req action.ConfigureRequest, resp *action.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	config, ok := req.ProviderData.(*HeadscaleProviderConfiguration)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Action Configure Type",
+			fmt.Sprintf("Expected *HeadscaleProviderConfiguration, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+
+		return
+	}
+
+	a.client = config.client
+	a.cache = config.cache
+}
+
+func (a *MoveNodeAction) Invoke(ctx context.Context, req action.InvokeRequest, resp *action.InvokeResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_move_node", "invoke")
+	defer endOperation(&resp.Diagnostics)
+	var data MoveNodeActionModel
+
+	// Read Terraform config data into the model
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if data.UserId.IsNull() {
+		user, err := findUserByName(ctx, a.client, data.User.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("user"), "User Error", err.Error())
+			return
+		}
+		data.UserId = types.Int64Value(int64(user.GetId()))
+	}
+	response, err := a.client.MoveNode(ctx, &v1.MoveNodeRequest{
+		NodeId: uint64(data.NodeId.ValueInt64()),
+		User:   uint64(data.UserId.ValueInt64()),
+	})
+	a.cache.InvalidateNodes()
+	if err != nil {
+		addClientError(&resp.Diagnostics, "move node", err)
+		return
+	}
+	resp.SendProgress(action.InvokeProgressEvent{
+		Message: fmt.Sprintf("node %d %q is moved to user %q", response.GetNode().GetId(), response.GetNode().GetGivenName(), response.GetNode().GetUser().GetName()),
+	})
+}
@@ -0,0 +1,231 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/listplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/paragor/terraform-provider-headscale/internal/headscaleclient"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &NodeRegistrationResource{}
+
+func NewNodeRegistrationResource() resource.Resource {
+	return &NodeRegistrationResource{}
+}
+
+// NodeRegistrationResource defines the resource implementation.
+type NodeRegistrationResource struct {
+	client headscaleclient.Client
+	cache  *snapshotCache
+}
+
+type NodeRegistrationResourceModel struct {
+	Id   types.Int64  `tfsdk:"id"`
+	Key  types.String `tfsdk:"key"`
+	User types.String `tfsdk:"user"`
+
+	UserId      types.Int64  `tfsdk:"user_id"`
+	Name        types.String `tfsdk:"name"`
+	GivenName   types.String `tfsdk:"given_name"`
+	IpAddresses types.List   `tfsdk:"ip_addresses"`
+}
+
+func (r *NodeRegistrationResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_node_registration"
+}
+
+func (r *NodeRegistrationResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		// This description is used by the documentation generator and the language server.
+		MarkdownDescription: "The resource approves registration of node that is logged in interactively via `tailscale up --login-server`, like `headscale nodes register`. Destroying the resource deletes the node.",
+
+		Attributes: map[string]schema.Attribute{
+			"id": schema.Int64Attribute{
+				Computed:            true,
+				MarkdownDescription: "ID of registered node",
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.UseStateForUnknown(),
+				},
+			},
+			"key": schema.StringAttribute{
+				Required:    true,
+				Sensitive:   true,
+				Description: "The registration key that headscale shows to the device on login.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"user": schema.StringAttribute{
+				Required:    true,
+				Description: "The name of the user who owns the node.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"user_id": schema.Int64Attribute{
+				Computed:    true,
+				Description: "The ID of the user who owns the node.",
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.UseStateForUnknown(),
+				},
+			},
+			"name": schema.StringAttribute{
+				Computed:    true,
+				Description: "The node's hostname.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"given_name": schema.StringAttribute{
+				Computed:    true,
+				Description: "The node's name in MagicDNS.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"ip_addresses": schema.ListAttribute{
+				Computed:    true,
+				ElementType: types.StringType,
+				Description: "The node's tailnet IP addresses.",
+				PlanModifiers: []planmodifier.List{
+					listplanmodifier.UseStateForUnknown(),
+				},
+			},
+		},
+	}
+}
+
+func (r *NodeRegistrationResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	config, ok := req.ProviderData.(*HeadscaleProviderConfiguration)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Resource Configure Type",
+			fmt.Sprintf("Expected *HeadscaleProviderConfiguration, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+
+		return
+	}
+
+	r.client = config.client
+	r.cache = config.cache
+}
+
+func (r *NodeRegistrationResource) readComputedFields(
+	ctx context.Context,
+	node *v1.Node,
+	data *NodeRegistrationResourceModel,
+) diag.Diagnostics {
+	data.Id = types.Int64Value(int64(node.GetId()))
+	data.UserId = types.Int64Value(int64(node.GetUser().GetId()))
+	data.Name = types.StringValue(node.GetName())
+	data.GivenName = types.StringValue(node.GetGivenName())
+	ipAddresses := node.GetIpAddresses()
+	if ipAddresses == nil {
+		ipAddresses = make([]string, 0)
+	}
+	list, diags := types.ListValueFrom(ctx, types.StringType, ipAddresses)
+	data.IpAddresses = list
+	return diags
+}
+
+func (r *NodeRegistrationResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_node_registration", "create")
+	defer endOperation(&resp.Diagnostics)
+	var data NodeRegistrationResourceModel
+
+	// Read Terraform plan data into the model
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	response, err := r.client.RegisterNode(ctx, &v1.RegisterNodeRequest{
+		User: data.User.ValueString(),
+		Key:  data.Key.ValueString(),
+	})
+	r.cache.InvalidateNodes()
+	if err != nil {
+		addClientAttributeError(&resp.Diagnostics, path.Root("key"), "register node", err)
+		return
+	}
+	resp.Diagnostics.Append(r.readComputedFields(ctx, response.GetNode(), &data)...)
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *NodeRegistrationResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_node_registration", "read")
+	defer endOperation(&resp.Diagnostics)
+	var data NodeRegistrationResourceModel
+
+	// Read Terraform prior state data into the model
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	node, err := r.cache.Node(ctx, uint64(data.Id.ValueInt64()))
+	if err != nil {
+		addClientError(&resp.Diagnostics, "list nodes", err)
+		return
+	}
+	if node == nil {
+		removeNotFoundResource(ctx, &resp.State, &resp.Diagnostics, fmt.Sprintf("node %d", data.Id.ValueInt64()))
+		return
+	}
+	resp.Diagnostics.Append(r.readComputedFields(ctx, node, &data)...)
+
+	// Save updated data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *NodeRegistrationResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	resp.Diagnostics.AddError(
+		"Error updating node registration",
+		"node registration cannot be updated",
+	)
+}
+
+func (r *NodeRegistrationResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_node_registration", "delete")
+	defer endOperation(&resp.Diagnostics)
+	var data NodeRegistrationResourceModel
+
+	// Read Terraform prior state data into the model
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	_, err := r.client.DeleteNode(ctx, &v1.DeleteNodeRequest{
+		NodeId: uint64(data.Id.ValueInt64()),
+	})
+	r.cache.InvalidateNodes()
+	if err != nil && !headscaleclient.IsNotFound(err) {
+		addClientError(&resp.Diagnostics, "delete node", err)
+		return
+	}
+}
@@ -6,11 +6,9 @@ package provider
 import (
 	"context"
 	"fmt"
-	"regexp"
 	"strconv"
 
 	"github.com/hashicorp/terraform-plugin-framework-validators/setvalidator"
-	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
 	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
@@ -20,12 +18,13 @@ import (
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/setplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
-	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/paragor/terraform-provider-headscale/internal/headscaleclient"
 )
 
 // Ensure provider defined types fully satisfy framework interfaces.
 var _ resource.Resource = &NodeRoutesResource{}
 var _ resource.ResourceWithImportState = &NodeRoutesResource{}
+var _ resource.ResourceWithUpgradeState = &NodeRoutesResource{}
 
 func NewNodeRoutesResource() resource.Resource {
 	return &NodeRoutesResource{}
@@ -33,7 +32,8 @@ func NewNodeRoutesResource() resource.Resource {
 
 // NodeRoutesResource defines the resource implementation.
 type NodeRoutesResource struct {
-	client v1.HeadscaleServiceClient
+	routes headscaleclient.NodeRoutes
+	cache  *snapshotCache
 }
 
 type NodeRoutesResourceModel struct {
@@ -48,6 +48,8 @@ func (r *NodeRoutesResource) Metadata(ctx context.Context, req resource.Metadata
 
 func (r *NodeRoutesResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
+		Version: nodeRoutesResourceVersion,
+
 		// This description is used by the documentation generator and the language server.
 		MarkdownDescription: "The resource approves node routes",
 
@@ -69,14 +71,12 @@ func (r *NodeRoutesResource) Schema(ctx context.Context, req resource.SchemaRequ
 			"routes": schema.SetAttribute{
 				Required:    true,
 				ElementType: types.StringType,
-				Description: `Approved routes on the node. e.g. "10.0.0.0/8" or "192.168.0.0/24"`,
+				Description: `Approved routes on the node. e.g. "10.0.0.0/8" or "192.168.0.0/24". Every route must be advertised by the node`,
 				PlanModifiers: []planmodifier.Set{
 					setplanmodifier.UseStateForUnknown(),
 				},
 				Validators: []validator.Set{
-					setvalidator.ValueStringsAre(
-						stringvalidator.RegexMatches(regexp.MustCompile(`.+/.+`), "tag must follow scheme of `net/mask`"),
-					),
+					setvalidator.ValueStringsAre(routeValidator()),
 				},
 			},
 		},
@@ -99,15 +99,15 @@ func (r *NodeRoutesResource) Configure(ctx context.Context, req resource.Configu
 		return
 	}
 
-	r.client = config.client
+	r.cache = config.cache
+	r.routes = config.nodeRoutes
 }
 
 func (r *NodeRoutesResource) readComputedFields(
 	ctx context.Context,
-	node *v1.Node,
+	approvedRoutes []string,
 	data *NodeRoutesResourceModel,
 ) diag.Diagnostics {
-	approvedRoutes := node.GetApprovedRoutes()
 	if approvedRoutes == nil {
 		approvedRoutes = make([]string, 0)
 	}
@@ -117,6 +117,8 @@ func (r *NodeRoutesResource) readComputedFields(
 }
 
 func (r *NodeRoutesResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_node_routes", "create")
+	defer endOperation(&resp.Diagnostics)
 	var data NodeRoutesResourceModel
 
 	// Read Terraform plan data into the model
@@ -133,20 +135,20 @@ func (r *NodeRoutesResource) Create(ctx context.Context, req resource.CreateRequ
 		routes = append(routes, conv.ValueString())
 	}
 
-	response, err := r.client.SetApprovedRoutes(ctx, &v1.SetApprovedRoutesRequest{
-		NodeId: uint64(data.NodeId.ValueInt64()),
-		Routes: routes,
-	})
+	approvedRoutes, err := r.routes.SetApprovedRoutes(ctx, uint64(data.NodeId.ValueInt64()), routes)
+	r.cache.InvalidateNodes()
 	if err != nil {
-		resp.Diagnostics.AddError("Client Error", fmt.Sprintf("Unable to set node routes, got error: %s", err))
+		addClientAttributeError(&resp.Diagnostics, path.Root("routes"), "set node routes", err)
 		return
 	}
-	resp.Diagnostics.Append(r.readComputedFields(ctx, response.GetNode(), &data)...)
+	resp.Diagnostics.Append(r.readComputedFields(ctx, approvedRoutes, &data)...)
 	// Save data into Terraform state
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
 func (r *NodeRoutesResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_node_routes", "read")
+	defer endOperation(&resp.Diagnostics)
 	var data NodeRoutesResourceModel
 
 	// Read Terraform prior state data into the model
@@ -156,22 +158,28 @@ func (r *NodeRoutesResource) Read(ctx context.Context, req resource.ReadRequest,
 		return
 	}
 
-	response, err := r.client.GetNode(ctx, &v1.GetNodeRequest{NodeId: uint64(data.NodeId.ValueInt64())})
+	node, err := r.cache.Node(ctx, uint64(data.NodeId.ValueInt64()))
 	if err != nil {
-		resp.Diagnostics.AddError("Client Error", fmt.Sprintf("Unable to list nodes routes, got error: %s", err))
+		addClientError(&resp.Diagnostics, "list nodes routes", err)
+		return
+	}
+	if node == nil {
+		removeNotFoundResource(ctx, &resp.State, &resp.Diagnostics, fmt.Sprintf("node %d", data.NodeId.ValueInt64()))
 		return
 	}
-	if response.GetNode() == nil {
-		resp.Diagnostics.AddError("node not found", "node not found")
-		resp.State.RemoveResource(ctx)
+	approvedRoutes, err := r.routes.ApprovedRoutes(ctx, node)
+	if err != nil {
+		addClientError(&resp.Diagnostics, "read node routes", err)
 		return
 	}
-	resp.Diagnostics.Append(r.readComputedFields(ctx, response.GetNode(), &data)...)
+	resp.Diagnostics.Append(r.readComputedFields(ctx, approvedRoutes, &data)...)
 	// Save updated data into Terraform state
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
 func (r *NodeRoutesResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_node_routes", "update")
+	defer endOperation(&resp.Diagnostics)
 	var data NodeRoutesResourceModel
 
 	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
@@ -181,29 +189,28 @@ func (r *NodeRoutesResource) Update(ctx context.Context, req resource.UpdateRequ
 	}
 	routes := []string{}
 	req.Plan.GetAttribute(ctx, path.Root("routes"), &routes)
-	response, err := r.client.SetApprovedRoutes(ctx, &v1.SetApprovedRoutesRequest{
-		NodeId: uint64(data.NodeId.ValueInt64()),
-		Routes: routes,
-	})
+	approvedRoutes, err := r.routes.SetApprovedRoutes(ctx, uint64(data.NodeId.ValueInt64()), routes)
+	r.cache.InvalidateNodes()
 	if err != nil {
-		resp.Diagnostics.AddError("Client Error", fmt.Sprintf("Unable to set node routes, got error: %s", err))
+		addClientAttributeError(&resp.Diagnostics, path.Root("routes"), "set node routes", err)
 		return
 	}
-	resp.Diagnostics.Append(r.readComputedFields(ctx, response.GetNode(), &data)...)
+	resp.Diagnostics.Append(r.readComputedFields(ctx, approvedRoutes, &data)...)
 
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
 func (r *NodeRoutesResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_node_routes", "delete")
+	defer endOperation(&resp.Diagnostics)
 	var data NodeRoutesResourceModel
 
 	// Read Terraform prior state data into the model
 	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
-	_, err := r.client.SetApprovedRoutes(ctx, &v1.SetApprovedRoutesRequest{
-		NodeId: uint64(data.NodeId.ValueInt64()),
-	})
-	if err != nil {
-		resp.Diagnostics.AddError("Client Error", fmt.Sprintf("Unable to set node routes, got error: %s", err))
+	_, err := r.routes.SetApprovedRoutes(ctx, uint64(data.NodeId.ValueInt64()), nil)
+	r.cache.InvalidateNodes()
+	if err != nil && !headscaleclient.IsNotFound(err) {
+		addClientError(&resp.Diagnostics, "set node routes", err)
 		return
 	}
 
@@ -225,3 +232,26 @@ func (r *NodeRoutesResource) ImportState(ctx context.Context, req resource.Impor
 	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("node_id"), typedId)...)
 	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), typedId)...)
 }
+
+func (r *NodeRoutesResource) UpgradeState(ctx context.Context) map[int64]resource.StateUpgrader {
+	return map[int64]resource.StateUpgrader{
+		0: {
+			PriorSchema: &schema.Schema{
+				Attributes: map[string]schema.Attribute{
+					"id":      schema.Int64Attribute{Computed: true},
+					"node_id": schema.Int64Attribute{Required: true},
+					"routes":  schema.SetAttribute{Required: true, ElementType: types.StringType},
+				},
+			},
+			StateUpgrader: func(ctx context.Context, req resource.UpgradeStateRequest, resp *resource.UpgradeStateResponse) {
+				var data NodeRoutesResourceModel
+				resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+				if resp.Diagnostics.HasError() {
+					return
+				}
+				data.Id = upgradeNodeId(data.Id, data.NodeId)
+				resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+			},
+		},
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"fmt"
+	"net/netip"
+	"path"
+	"regexp"
+	"slices"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	tfpath "github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+)
+
+// NodeSelectorModel selects nodes by attributes, all set criteria must match.
+type NodeSelectorModel struct {
+	User         types.String `tfsdk:"user"`
+	Tag          types.String `tfsdk:"tag"`
+	NameRegex    types.String `tfsdk:"name_regex"`
+	HostnameGlob types.String `tfsdk:"hostname_glob"`
+	IpPrefix     types.String `tfsdk:"ip_prefix"`
+}
+
+func nodeSelectorAttribute(description string) schema.SingleNestedAttribute {
+	atLeastOne := stringvalidator.AtLeastOneOf(
+		tfpath.MatchRelative().AtParent().AtName("user"),
+		tfpath.MatchRelative().AtParent().AtName("tag"),
+		tfpath.MatchRelative().AtParent().AtName("name_regex"),
+		tfpath.MatchRelative().AtParent().AtName("hostname_glob"),
+		tfpath.MatchRelative().AtParent().AtName("ip_prefix"),
+	)
+	return schema.SingleNestedAttribute{
+		Required:            true,
+		MarkdownDescription: description + " At least one criterion must be set, node must match all set criteria.",
+		Attributes: map[string]schema.Attribute{
+			"user": schema.StringAttribute{
+				Optional:    true,
+				Description: "The name of the user who owns the node.",
+				Validators: []validator.String{
+					atLeastOne,
+				},
+			},
+			"tag": schema.StringAttribute{
+				Optional:    true,
+				Description: "The tag of the node, forced or valid by policy.",
+				Validators: []validator.String{
+					tagValidator(),
+				},
+			},
+			"name_regex": schema.StringAttribute{
+				Optional:    true,
+				Description: "Regular expression that must match the node's hostname or given name.",
+				Validators: []validator.String{
+					regexpValidator(),
+				},
+			},
+			"hostname_glob": schema.StringAttribute{
+				Optional:    true,
+				Description: `Glob pattern that must match the node's hostname, for example "runner-*".`,
+				Validators: []validator.String{
+					globValidator(),
+				},
+			},
+			"ip_prefix": schema.StringAttribute{
+				Optional:    true,
+				Description: `Prefix that must contain one of the node's IP addresses, for example "100.64.1.0/24".`,
+				Validators: []validator.String{
+					routeValidator(),
+				},
+			},
+		},
+	}
+}
+
+// nodeMatcher returns function that checks if node matches selector.
+func (s NodeSelectorModel) nodeMatcher() (func(node *v1.Node) bool, error) {
+	var nameRegexp *regexp.Regexp
+	if !s.NameRegex.IsNull() {
+		var err error
+		nameRegexp, err = regexp.Compile(s.NameRegex.ValueString())
+		if err != nil {
+			return nil, fmt.Errorf("invalid name_regex: %w", err)
+		}
+	}
+	var ipPrefix netip.Prefix
+	if !s.IpPrefix.IsNull() {
+		var err error
+		ipPrefix, err = parseRoute(s.IpPrefix.ValueString())
+		if err != nil {
+			return nil, fmt.Errorf("invalid ip_prefix: %w", err)
+		}
+	}
+	return func(node *v1.Node) bool {
+		if !s.User.IsNull() && node.GetUser().GetName() != s.User.ValueString() {
+			return false
+		}
+		if !s.Tag.IsNull() && !slices.Contains(nodeTags(node), s.Tag.ValueString()) {
+			return false
+		}
+		if nameRegexp != nil && !nameRegexp.MatchString(node.GetName()) && !nameRegexp.MatchString(node.GetGivenName()) {
+			return false
+		}
+		if !s.HostnameGlob.IsNull() {
+			if matched, _ := path.Match(s.HostnameGlob.ValueString(), node.GetName()); !matched {
+				return false
+			}
+		}
+		if ipPrefix.IsValid() && !slices.ContainsFunc(node.GetIpAddresses(), func(ip string) bool {
+			addr, err := netip.ParseAddr(ip)
+			return err == nil && ipPrefix.Contains(addr)
+		}) {
+			return false
+		}
+		return true
+	}, nil
+}
+
+// nodeTags returns forced and valid by policy tags of node.
+func nodeTags(node *v1.Node) []string {
+	tags := append([]string{}, node.GetForcedTags()...)
+	for _, tag := range node.GetValidTags() {
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
@@ -6,11 +6,9 @@ package provider
 import (
 	"context"
 	"fmt"
-	"regexp"
 	"strconv"
 
 	"github.com/hashicorp/terraform-plugin-framework-validators/setvalidator"
-	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
 	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
@@ -20,12 +18,13 @@ import (
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/setplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
-	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/paragor/terraform-provider-headscale/internal/headscaleclient"
 )
 
 // Ensure provider defined types fully satisfy framework interfaces.
 var _ resource.Resource = &NodeTagsResource{}
 var _ resource.ResourceWithImportState = &NodeTagsResource{}
+var _ resource.ResourceWithUpgradeState = &NodeTagsResource{}
 
 func NewNodeTagsResource() resource.Resource {
 	return &NodeTagsResource{}
@@ -33,7 +32,8 @@ func NewNodeTagsResource() resource.Resource {
 
 // NodeTagsResource defines the resource implementation.
 type NodeTagsResource struct {
-	client v1.HeadscaleServiceClient
+	tags  headscaleclient.NodeTags
+	cache *snapshotCache
 }
 
 type NodeTagsResourceModel struct {
@@ -48,6 +48,8 @@ func (r *NodeTagsResource) Metadata(ctx context.Context, req resource.MetadataRe
 
 func (r *NodeTagsResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
+		Version: nodeTagsResourceVersion,
+
 		// This description is used by the documentation generator and the language server.
 		MarkdownDescription: "The node tags resource that create node tags",
 
@@ -75,7 +77,7 @@ func (r *NodeTagsResource) Schema(ctx context.Context, req resource.SchemaReques
 				},
 				Validators: []validator.Set{
 					setvalidator.ValueStringsAre(
-						stringvalidator.RegexMatches(regexp.MustCompile(`tag:[\w-]+`), "tag must follow scheme of `tag:<value>`"),
+						tagValidator(),
 					),
 				},
 			},
@@ -99,24 +101,26 @@ func (r *NodeTagsResource) Configure(ctx context.Context, req resource.Configure
 		return
 	}
 
-	r.client = config.client
+	r.cache = config.cache
+	r.tags = config.nodeTags
 }
 
 func (r *NodeTagsResource) readComputedFields(
 	ctx context.Context,
-	node *v1.Node,
+	nodeTags []string,
 	data *NodeTagsResourceModel,
 ) diag.Diagnostics {
-	forcedTags := node.GetForcedTags()
-	if forcedTags == nil {
-		forcedTags = make([]string, 0)
+	if nodeTags == nil {
+		nodeTags = make([]string, 0)
 	}
-	tags, diags := types.SetValueFrom(ctx, types.StringType, forcedTags)
+	tags, diags := types.SetValueFrom(ctx, types.StringType, nodeTags)
 	data.Tags = tags
 	return diags
 }
 
 func (r *NodeTagsResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_node_tags", "create")
+	defer endOperation(&resp.Diagnostics)
 	var data NodeTagsResourceModel
 
 	// Read Terraform plan data into the model
@@ -133,20 +137,20 @@ func (r *NodeTagsResource) Create(ctx context.Context, req resource.CreateReques
 		tags = append(tags, conv.ValueString())
 	}
 
-	response, err := r.client.SetTags(ctx, &v1.SetTagsRequest{
-		NodeId: uint64(data.NodeId.ValueInt64()),
-		Tags:   tags,
-	})
+	nodeTags, err := r.tags.SetTags(ctx, uint64(data.NodeId.ValueInt64()), tags)
+	r.cache.InvalidateNodes()
 	if err != nil {
-		resp.Diagnostics.AddError("Client Error", fmt.Sprintf("Unable to set node tags, got error: %s", err))
+		addClientAttributeError(&resp.Diagnostics, path.Root("tags"), "set node tags", err)
 		return
 	}
-	resp.Diagnostics.Append(r.readComputedFields(ctx, response.GetNode(), &data)...)
+	resp.Diagnostics.Append(r.readComputedFields(ctx, nodeTags, &data)...)
 	// Save data into Terraform state
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
 func (r *NodeTagsResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_node_tags", "read")
+	defer endOperation(&resp.Diagnostics)
 	var data NodeTagsResourceModel
 
 	// Read Terraform prior state data into the model
@@ -156,22 +160,28 @@ func (r *NodeTagsResource) Read(ctx context.Context, req resource.ReadRequest, r
 		return
 	}
 
-	response, err := r.client.GetNode(ctx, &v1.GetNodeRequest{NodeId: uint64(data.NodeId.ValueInt64())})
+	node, err := r.cache.Node(ctx, uint64(data.NodeId.ValueInt64()))
 	if err != nil {
-		resp.Diagnostics.AddError("Client Error", fmt.Sprintf("Unable to list nodes tags, got error: %s", err))
+		addClientError(&resp.Diagnostics, "list nodes tags", err)
+		return
+	}
+	if node == nil {
+		removeNotFoundResource(ctx, &resp.State, &resp.Diagnostics, fmt.Sprintf("node %d", data.NodeId.ValueInt64()))
 		return
 	}
-	if response.GetNode() == nil {
-		resp.Diagnostics.AddError("node not found", "node not found")
-		resp.State.RemoveResource(ctx)
+	nodeTags, err := r.tags.Tags(ctx, node)
+	if err != nil {
+		addClientError(&resp.Diagnostics, "read node tags", err)
 		return
 	}
-	resp.Diagnostics.Append(r.readComputedFields(ctx, response.GetNode(), &data)...)
+	resp.Diagnostics.Append(r.readComputedFields(ctx, nodeTags, &data)...)
 	// Save updated data into Terraform state
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
 func (r *NodeTagsResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_node_tags", "update")
+	defer endOperation(&resp.Diagnostics)
 	var data NodeTagsResourceModel
 
 	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
@@ -181,29 +191,28 @@ func (r *NodeTagsResource) Update(ctx context.Context, req resource.UpdateReques
 	}
 	tags := []string{}
 	req.Plan.GetAttribute(ctx, path.Root("tags"), &tags)
-	response, err := r.client.SetTags(ctx, &v1.SetTagsRequest{
-		NodeId: uint64(data.NodeId.ValueInt64()),
-		Tags:   tags,
-	})
+	nodeTags, err := r.tags.SetTags(ctx, uint64(data.NodeId.ValueInt64()), tags)
+	r.cache.InvalidateNodes()
 	if err != nil {
-		resp.Diagnostics.AddError("Client Error", fmt.Sprintf("Unable to set node tags, got error: %s", err))
+		addClientAttributeError(&resp.Diagnostics, path.Root("tags"), "set node tags", err)
 		return
 	}
-	resp.Diagnostics.Append(r.readComputedFields(ctx, response.GetNode(), &data)...)
+	resp.Diagnostics.Append(r.readComputedFields(ctx, nodeTags, &data)...)
 
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
 func (r *NodeTagsResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_node_tags", "delete")
+	defer endOperation(&resp.Diagnostics)
 	var data NodeTagsResourceModel
 
 	// Read Terraform prior state data into the model
 	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
-	_, err := r.client.SetTags(ctx, &v1.SetTagsRequest{
-		NodeId: uint64(data.NodeId.ValueInt64()),
-	})
-	if err != nil {
-		resp.Diagnostics.AddError("Client Error", fmt.Sprintf("Unable to set node tags, got error: %s", err))
+	_, err := r.tags.SetTags(ctx, uint64(data.NodeId.ValueInt64()), nil)
+	r.cache.InvalidateNodes()
+	if err != nil && !headscaleclient.IsNotFound(err) {
+		addClientError(&resp.Diagnostics, "set node tags", err)
 		return
 	}
 
@@ -226,3 +235,26 @@ func (r *NodeTagsResource) ImportState(ctx context.Context, req resource.ImportS
 	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("node_id"), typedId)...)
 	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), typedId)...)
 }
+
+func (r *NodeTagsResource) UpgradeState(ctx context.Context) map[int64]resource.StateUpgrader {
+	return map[int64]resource.StateUpgrader{
+		0: {
+			PriorSchema: &schema.Schema{
+				Attributes: map[string]schema.Attribute{
+					"id":      schema.Int64Attribute{Computed: true},
+					"node_id": schema.Int64Attribute{Required: true},
+					"tags":    schema.SetAttribute{Required: true, ElementType: types.StringType},
+				},
+			},
+			StateUpgrader: func(ctx context.Context, req resource.UpgradeStateRequest, resp *resource.UpgradeStateResponse) {
+				var data NodeTagsResourceModel
+				resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+				if resp.Diagnostics.HasError() {
+					return
+				}
+				data.Id = upgradeNodeId(data.Id, data.NodeId)
+				resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+			},
+		},
+	}
+}
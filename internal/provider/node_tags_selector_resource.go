@@ -0,0 +1,390 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/setvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/paragor/terraform-provider-headscale/internal/headscaleclient"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &NodeTagsSelectorResource{}
+var _ resource.ResourceWithModifyPlan = &NodeTagsSelectorResource{}
+
+func NewNodeTagsSelectorResource() resource.Resource {
+	return &NodeTagsSelectorResource{}
+}
+
+// NodeTagsSelectorResource defines the resource implementation.
+type NodeTagsSelectorResource struct {
+	tags  headscaleclient.NodeTags
+	cache *snapshotCache
+}
+
+type NodeTagsSelectorResourceModel struct {
+	Id       types.String       `tfsdk:"id"`
+	Selector *NodeSelectorModel `tfsdk:"selector"`
+	Tags     types.Set          `tfsdk:"tags"`
+	Nodes    types.Map          `tfsdk:"nodes"`
+}
+
+var nodeTagsSelectorNodesType = types.MapType{ElemType: types.SetType{ElemType: types.StringType}}
+
+func (r *NodeTagsSelectorResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_node_tags_selector"
+}
+
+func (r *NodeTagsSelectorResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		// This description is used by the documentation generator and the language server.
+		MarkdownDescription: "The resource adds tags to every node that matches selector. " +
+			"Every plan finds matching nodes, so newly registered nodes are tagged on the next apply and nodes that do not match anymore lose the tags that the resource added. " +
+			"Other tags of the node are kept, so selectors with different tags can match the same node, but the node must not be managed by `headscale_node_tags`, which replaces tags. " +
+			"Destroying the resource removes only the tags that it added. " +
+			"`selector.tag` does not have to be in `tags`: tag that node has without the resource is never removed, so the node keeps matching.",
+
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "ID of resources",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"selector": nodeSelectorAttribute("Selector of nodes to tag."),
+			"tags": schema.SetAttribute{
+				Required:    true,
+				ElementType: types.StringType,
+				Description: "ACL tags on the nodes.",
+				Validators: []validator.Set{
+					setvalidator.ValueStringsAre(
+						tagValidator(),
+					),
+				},
+			},
+			"nodes": schema.MapAttribute{
+				Computed:    true,
+				ElementType: types.SetType{ElemType: types.StringType},
+				Description: "Tags that the resource added to matching nodes by node id, tags that node already had are not included.",
+			},
+		},
+	}
+}
+
+func (r *NodeTagsSelectorResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	config, ok := req.ProviderData.(*HeadscaleProviderConfiguration)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Resource Configure Type",
+			fmt.Sprintf("Expected *HeadscaleProviderConfiguration, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+
+		return
+	}
+
+	r.cache = config.cache
+	r.tags = config.nodeTags
+}
+
+func (r *NodeTagsSelectorResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	// provider is not configured yet or resource is destroyed
+	if r.cache == nil || req.Plan.Raw.IsNull() {
+		return
+	}
+
+	if !req.Config.Raw.IsFullyKnown() {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("nodes"), types.MapUnknown(nodeTagsSelectorNodesType.ElemType))...)
+		return
+	}
+	var data NodeTagsSelectorResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	prior := types.MapNull(nodeTagsSelectorNodesType.ElemType)
+	if !req.State.Raw.IsNull() {
+		resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("nodes"), &prior)...)
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	nodes, diags := r.matchingNodes(ctx, prior, data)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("nodes"), nodes)...)
+}
+
+// matchingNodes returns map of matching node ids to tags that the resource adds to them.
+// Tags that node has without the resource are not added, so they are kept when node stops matching.
+func (r *NodeTagsSelectorResource) matchingNodes(ctx context.Context, prior types.Map, data NodeTagsSelectorResourceModel) (types.Map, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	empty := types.MapNull(nodeTagsSelectorNodesType.ElemType)
+	matches, err := data.Selector.nodeMatcher()
+	if err != nil {
+		diags.AddAttributeError(path.Root("selector"), "Selector Error", err.Error())
+		return empty, diags
+	}
+	var tags []string
+	diags.Append(data.Tags.ElementsAs(ctx, &tags, false)...)
+	added := nodeTagsSelectorAdded(ctx, prior, &diags)
+	if diags.HasError() {
+		return empty, diags
+	}
+	nodes, err := r.cache.Nodes(ctx)
+	if err != nil {
+		addClientError(&diags, "list nodes", err)
+		return empty, diags
+	}
+	result := map[string][]string{}
+	for _, node := range nodes {
+		if !matches(node) {
+			continue
+		}
+		nodeTags, err := r.tags.Tags(ctx, node)
+		if err != nil {
+			addClientError(&diags, "read node tags", err)
+			return empty, diags
+		}
+		id := strconv.FormatUint(node.GetId(), 10)
+		own := subtractTags(nodeTags, added[id])
+		result[id] = subtractTags(tags, own)
+	}
+	value, mapDiags := types.MapValueFrom(ctx, nodeTagsSelectorNodesType.ElemType, result)
+	diags.Append(mapDiags...)
+	return value, diags
+}
+
+// apply adds planned tags to nodes and removes tags that the resource added before but does not add anymore,
+// for example from nodes that do not match selector anymore. Other tags of nodes are kept.
+func (r *NodeTagsSelectorResource) apply(ctx context.Context, prior types.Map, data *NodeTagsSelectorResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+	if data.Nodes.IsUnknown() {
+		nodes, nodesDiags := r.matchingNodes(ctx, prior, *data)
+		diags.Append(nodesDiags...)
+		if diags.HasError() {
+			return diags
+		}
+		data.Nodes = nodes
+	}
+
+	planned := map[string][]string{}
+	diags.Append(data.Nodes.ElementsAs(ctx, &planned, false)...)
+	current := nodeTagsSelectorAdded(ctx, prior, &diags)
+	if diags.HasError() {
+		return diags
+	}
+
+	defer r.cache.InvalidateNodes()
+	for id, tags := range planned {
+		if currentTags, ok := current[id]; ok && sameTags(currentTags, tags) {
+			continue
+		}
+		r.updateTags(ctx, id, current[id], tags, &diags)
+	}
+	for id, tags := range current {
+		if _, ok := planned[id]; !ok {
+			r.updateTags(ctx, id, tags, nil, &diags)
+		}
+	}
+	return diags
+}
+
+// updateTags removes tags that the resource added before and adds planned tags to node.
+func (r *NodeTagsSelectorResource) updateTags(ctx context.Context, id string, prior []string, planned []string, diags *diag.Diagnostics) {
+	nodeId, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		diags.AddError("Node Id Error", fmt.Sprintf("Unable to parse node id %q, got error: %s", id, err))
+		return
+	}
+	node, err := r.cache.Node(ctx, nodeId)
+	if err != nil {
+		addClientError(diags, "list nodes", err)
+		return
+	}
+	if node == nil {
+		// node is deleted, there are no tags to change
+		return
+	}
+	nodeTags, err := r.tags.Tags(ctx, node)
+	if err != nil {
+		addClientError(diags, "read node tags", err)
+		return
+	}
+	tags := mergeTags(subtractTags(nodeTags, subtractTags(prior, planned)), planned)
+	if sameTags(tags, nodeTags) {
+		return
+	}
+	if _, err := r.tags.SetTags(ctx, nodeId, tags); err != nil && !(planned == nil && headscaleclient.IsNotFound(err)) {
+		addClientAttributeError(diags, path.Root("tags"), fmt.Sprintf("set tags of node %d", nodeId), err)
+	}
+}
+
+// nodeTagsSelectorAdded returns tags that the resource added by node id from nodes attribute.
+func nodeTagsSelectorAdded(ctx context.Context, nodes types.Map, diags *diag.Diagnostics) map[string][]string {
+	added := map[string][]string{}
+	if !nodes.IsNull() && !nodes.IsUnknown() {
+		diags.Append(nodes.ElementsAs(ctx, &added, false)...)
+	}
+	return added
+}
+
+func sameTags(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
+
+// subtractTags returns sorted tags of a that are not in b.
+func subtractTags(a, b []string) []string {
+	result := make([]string, 0, len(a))
+	for _, tag := range a {
+		if !slices.Contains(b, tag) && !slices.Contains(result, tag) {
+			result = append(result, tag)
+		}
+	}
+	slices.Sort(result)
+	return result
+}
+
+// intersectTags returns sorted tags of a that are in b.
+func intersectTags(a, b []string) []string {
+	return subtractTags(a, subtractTags(a, b))
+}
+
+// mergeTags returns sorted tags of a and b without duplicates.
+func mergeTags(a, b []string) []string {
+	result := append(subtractTags(a, b), subtractTags(b, nil)...)
+	slices.Sort(result)
+	return result
+}
+
+func (r *NodeTagsSelectorResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_node_tags_selector", "create")
+	defer endOperation(&resp.Diagnostics)
+	var data NodeTagsSelectorResourceModel
+
+	// Read Terraform plan data into the model
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(r.apply(ctx, types.MapNull(nodeTagsSelectorNodesType.ElemType), &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	data.Id = types.StringValue(time.Now().UTC().Format(time.RFC3339))
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *NodeTagsSelectorResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_node_tags_selector", "read")
+	defer endOperation(&resp.Diagnostics)
+	var data NodeTagsSelectorResourceModel
+
+	// Read Terraform prior state data into the model
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	added := nodeTagsSelectorAdded(ctx, data.Nodes, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	result := map[string][]string{}
+	for id, tags := range added {
+		nodeId, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			continue
+		}
+		node, err := r.cache.Node(ctx, nodeId)
+		if err != nil {
+			addClientError(&resp.Diagnostics, "list nodes tags", err)
+			return
+		}
+		if node == nil {
+			continue
+		}
+		nodeTags, err := r.tags.Tags(ctx, node)
+		if err != nil {
+			addClientError(&resp.Diagnostics, "read node tags", err)
+			return
+		}
+		// tags that the resource added and that are removed from node since then are added again by the next apply
+		result[id] = intersectTags(tags, nodeTags)
+	}
+	value, diags := types.MapValueFrom(ctx, nodeTagsSelectorNodesType.ElemType, result)
+	resp.Diagnostics.Append(diags...)
+	data.Nodes = value
+
+	// Save updated data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *NodeTagsSelectorResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_node_tags_selector", "update")
+	defer endOperation(&resp.Diagnostics)
+	var data, state NodeTagsSelectorResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(r.apply(ctx, state.Nodes, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *NodeTagsSelectorResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_node_tags_selector", "delete")
+	defer endOperation(&resp.Diagnostics)
+	var data NodeTagsSelectorResourceModel
+
+	// Read Terraform prior state data into the model
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	added := nodeTagsSelectorAdded(ctx, data.Nodes, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	defer r.cache.InvalidateNodes()
+	for id, tags := range added {
+		r.updateTags(ctx, id, tags, nil, &resp.Diagnostics)
+	}
+}
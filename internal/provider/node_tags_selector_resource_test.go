@@ -0,0 +1,119 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/paragor/terraform-provider-headscale/internal/headscaleclient"
+)
+
+// fakeTaggedNodes returns node tags selector resource over nodes whose forced tags are changed by SetTags.
+func fakeTaggedNodes(nodes []*v1.Node) (*NodeTagsSelectorResource, map[uint64][]string) {
+	setTags := map[uint64][]string{}
+	client := &fakeClient{
+		listNodes: func(ctx context.Context, _ *v1.ListNodesRequest) (*v1.ListNodesResponse, error) {
+			return &v1.ListNodesResponse{Nodes: nodes}, nil
+		},
+		setTags: func(ctx context.Context, in *v1.SetTagsRequest) (*v1.SetTagsResponse, error) {
+			setTags[in.GetNodeId()] = in.GetTags()
+			for _, node := range nodes {
+				if node.GetId() == in.GetNodeId() {
+					node.ForcedTags = in.GetTags()
+				}
+			}
+			return &v1.SetTagsResponse{Node: &v1.Node{Id: in.GetNodeId(), ForcedTags: in.GetTags()}}, nil
+		},
+	}
+	return &NodeTagsSelectorResource{
+		tags:  headscaleclient.NewNodeTags(client, headscaleclient.DefaultServerAPI()),
+		cache: newSnapshotCache(client),
+	}, setTags
+}
+
+func tagsMap(t *testing.T, nodes map[string][]string) types.Map {
+	t.Helper()
+	value, diags := types.MapValueFrom(context.Background(), nodeTagsSelectorNodesType.ElemType, nodes)
+	if diags.HasError() {
+		t.Fatalf("unexpected errors: %v", diags)
+	}
+	return value
+}
+
+func TestNodeTagsSelectorKeepsOtherTags(t *testing.T) {
+	ci := &v1.User{Name: "ci"}
+	nodes := []*v1.Node{
+		// tagged before, has own tag
+		{Id: 1, User: ci, ForcedTags: []string{"tag:ci", "tag:own"}},
+		// new match, has own tag that is in tags too
+		{Id: 2, User: ci, ForcedTags: []string{"tag:web"}},
+		// tagged before, does not match anymore
+		{Id: 3, User: &v1.User{Name: "alice"}, ForcedTags: []string{"tag:ci", "tag:keep"}},
+	}
+	resource, setTags := fakeTaggedNodes(nodes)
+	ctx := context.Background()
+	prior := tagsMap(t, map[string][]string{"1": {"tag:ci"}, "3": {"tag:ci"}})
+	data := NodeTagsSelectorResourceModel{
+		Selector: &NodeSelectorModel{User: types.StringValue("ci")},
+		Tags:     types.SetValueMust(types.StringType, []attr.Value{types.StringValue("tag:ci"), types.StringValue("tag:web")}),
+		Nodes:    types.MapUnknown(nodeTagsSelectorNodesType.ElemType),
+	}
+
+	if diags := resource.apply(ctx, prior, &data); diags.HasError() {
+		t.Fatalf("unexpected errors: %v", diags)
+	}
+
+	expectedSet := map[uint64][]string{
+		1: {"tag:ci", "tag:own", "tag:web"},
+		2: {"tag:ci", "tag:web"},
+		3: {"tag:keep"},
+	}
+	if !maps.EqualFunc(setTags, expectedSet, slices.Equal) {
+		t.Errorf("expected tags %v, got %v", expectedSet, setTags)
+	}
+	expectedAdded := tagsMap(t, map[string][]string{"1": {"tag:ci", "tag:web"}, "2": {"tag:ci"}})
+	if !data.Nodes.Equal(expectedAdded) {
+		t.Errorf("expected added tags %s, got %s", expectedAdded, data.Nodes)
+	}
+
+	// the next plan of the same config has no changes
+	resource.cache.InvalidateNodes()
+	planned, diags := resource.matchingNodes(ctx, data.Nodes, data)
+	if diags.HasError() {
+		t.Fatalf("unexpected errors: %v", diags)
+	}
+	if !planned.Equal(data.Nodes) {
+		t.Errorf("expected stable plan %s, got %s", data.Nodes, planned)
+	}
+}
+
+func TestNodeTagsSelectorDeleteRemovesAddedTags(t *testing.T) {
+	nodes := []*v1.Node{
+		{Id: 1, ForcedTags: []string{"tag:ci", "tag:own"}},
+		{Id: 2, ForcedTags: []string{"tag:web"}},
+	}
+	resource, setTags := fakeTaggedNodes(nodes)
+	ctx := context.Background()
+	added := tagsMap(t, map[string][]string{"1": {"tag:ci"}, "2": {}, "3": {"tag:ci"}})
+
+	var diags diag.Diagnostics
+	for id, tags := range nodeTagsSelectorAdded(ctx, added, &diags) {
+		resource.updateTags(ctx, id, tags, nil, &diags)
+	}
+	if diags.HasError() {
+		t.Fatalf("unexpected errors: %v", diags)
+	}
+	// node 2 has no added tags and node 3 is deleted, so their tags are not set
+	expected := map[uint64][]string{1: {"tag:own"}}
+	if !maps.EqualFunc(setTags, expected, slices.Equal) {
+		t.Errorf("expected tags %v, got %v", expected, setTags)
+	}
+}
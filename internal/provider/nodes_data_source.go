@@ -6,11 +6,11 @@ package provider
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/types"
-	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
 )
 
 // Ensure provider defined types fully satisfy framework interfaces.
@@ -22,7 +22,7 @@ func NewNodesDataSource() datasource.DataSource {
 
 // NodesDataSource defines the data source implementation.
 type NodesDataSource struct {
-	client v1.HeadscaleServiceClient
+	cache *snapshotCache
 }
 
 // NodesDataSourceModel describes the data source data model.
@@ -33,6 +33,10 @@ type NodeModel struct {
 	Id     types.Int64  `tfsdk:"id"`
 	Name   types.String `tfsdk:"name"`
 	UserId types.Int64  `tfsdk:"user_id"`
+	User   types.String `tfsdk:"user"`
+
+	Online   types.Bool   `tfsdk:"online"`
+	LastSeen types.String `tfsdk:"last_seen"`
 }
 
 func (d *NodesDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
@@ -62,6 +66,18 @@ func (d *NodesDataSource) Schema(ctx context.Context, req datasource.SchemaReque
 							Computed:    true,
 							Description: "The ID of the user who owns the device.",
 						},
+						"user": schema.StringAttribute{
+							Computed:    true,
+							Description: "The name of the user who owns the device.",
+						},
+						"online": schema.BoolAttribute{
+							Computed:    true,
+							Description: "Whether the device is connected to headscale.",
+						},
+						"last_seen": schema.StringAttribute{
+							Computed:    true,
+							Description: "The time when the device was seen last time.",
+						},
 					},
 				},
 			},
@@ -85,10 +101,12 @@ func (d *NodesDataSource) Configure(ctx context.Context, req datasource.Configur
 		return
 	}
 
-	d.client = config.client
+	d.cache = config.cache
 }
 
 func (d *NodesDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_nodes", "read")
+	defer endOperation(&resp.Diagnostics)
 	var data NodesDataSourceModel
 
 	// Read Terraform configuration data into the model
@@ -98,23 +116,26 @@ func (d *NodesDataSource) Read(ctx context.Context, req datasource.ReadRequest,
 		return
 	}
 
-	response, err := d.client.ListNodes(ctx, &v1.ListNodesRequest{})
+	nodes, err := d.cache.Nodes(ctx)
 	if err != nil {
-		resp.Diagnostics.AddError("Client Error", fmt.Sprintf("Unable to list nodes, got error: %s", err))
-		return
-	}
-	nodes := response.GetNodes()
-	if nodes == nil {
-		resp.Diagnostics.AddError("Client Error", "Headscale return null instead of list of nodes")
+		addClientError(&resp.Diagnostics, "list nodes", err)
 		return
 	}
 
 	result := make([]NodeModel, 0, len(nodes))
 	for _, node := range nodes {
+		lastSeen := types.StringNull()
+		if node.GetLastSeen() != nil {
+			lastSeen = types.StringValue(node.GetLastSeen().AsTime().Format(time.RFC3339))
+		}
 		result = append(result, NodeModel{
 			Id:     types.Int64Value(int64(node.GetId())),
 			Name:   types.StringValue(node.GetName()),
 			UserId: types.Int64Value(int64(node.GetUser().GetId())),
+			User:   types.StringValue(node.GetUser().GetName()),
+
+			Online:   types.BoolValue(node.GetOnline()),
+			LastSeen: lastSeen,
 		})
 	}
 	data.Nodes = result
@@ -0,0 +1,58 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ function.Function = &NormalizeCIDRFunction{}
+
+func NewNormalizeCIDRFunction() function.Function {
+	return &NormalizeCIDRFunction{}
+}
+
+// NormalizeCIDRFunction defines the function implementation.
+type NormalizeCIDRFunction struct{}
+
+func (f *NormalizeCIDRFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "normalize_cidr"
+}
+
+func (f *NormalizeCIDRFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:             "Normalize route prefix",
+		MarkdownDescription: "Returns route prefix in canonical form as headscale stores it: host bits are masked and IPv6 address is compressed, for example `10.1.2.3/8` becomes `10.0.0.0/8`.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:                "cidr",
+				MarkdownDescription: "Route prefix, for example `10.0.0.0/8`.",
+				Validators: []function.StringParameterValidator{
+					routeValidator(),
+				},
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+func (f *NormalizeCIDRFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var cidr string
+
+	resp.Error = function.ConcatFuncErrors(req.Arguments.Get(ctx, &cidr))
+	if resp.Error != nil {
+		return
+	}
+
+	prefix, err := parseRoute(cidr)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(0, err.Error())
+		return
+	}
+
+	resp.Error = function.ConcatFuncErrors(resp.Result.Set(ctx, prefix.Masked().String()))
+}
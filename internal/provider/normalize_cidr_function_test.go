@@ -0,0 +1,48 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestNormalizeCIDRFunction(t *testing.T) {
+	testCases := []struct {
+		cidr     string
+		expected string
+		error    bool
+	}{
+		{cidr: "10.0.0.0/8", expected: "10.0.0.0/8"},
+		{cidr: "10.1.2.3/8", expected: "10.0.0.0/8"},
+		{cidr: "192.168.1.77/24", expected: "192.168.1.0/24"},
+		{cidr: "0.0.0.0/0", expected: "0.0.0.0/0"},
+		{cidr: "10.0.0.1/32", expected: "10.0.0.1/32"},
+		{cidr: "fd7a:115c:a1e0:0000:0000:0000:0000:0001/48", expected: "fd7a:115c:a1e0::/48"},
+		{cidr: "::/0", expected: "::/0"},
+		{cidr: "10.0.0.0", error: true},
+		{cidr: "10.0.0.0/33", error: true},
+		{cidr: "example.com/8", error: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.cidr, func(t *testing.T) {
+			result, err := runFunction(t, NewNormalizeCIDRFunction(), []attr.Value{types.StringValue(testCase.cidr)}, types.StringUnknown())
+			if testCase.error {
+				if err == nil {
+					t.Fatalf("expected error, got %s", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !result.Equal(types.StringValue(testCase.expected)) {
+				t.Errorf("expected %s, got %s", testCase.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ function.Function = &ParsePreAuthKeyFunction{}
+
+func NewParsePreAuthKeyFunction() function.Function {
+	return &ParsePreAuthKeyFunction{}
+}
+
+// ParsePreAuthKeyFunction defines the function implementation.
+type ParsePreAuthKeyFunction struct{}
+
+var parsedPreAuthKeyAttributeTypes = map[string]attr.Type{
+	"format": types.StringType,
+	"prefix": types.StringType,
+}
+
+func (f *ParsePreAuthKeyFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "parse_pre_auth_key"
+}
+
+func (f *ParsePreAuthKeyFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary: "Parse pre auth key",
+		MarkdownDescription: "Checks pre auth key and returns object with attributes:\n" +
+			" - `format`: `legacy` for 48 hex characters keys of headscale v0.26 and older, `prefixed` for `hskey-auth-<prefix>-<secret>` keys of newer headscale\n" +
+			" - `prefix`: public prefix of `prefixed` key, null for `legacy` key",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:                "key",
+				MarkdownDescription: "Pre auth key, for example `key` of `headscale_pre_auth_key`.",
+				Validators: []function.StringParameterValidator{
+					preAuthKeyValidator(),
+				},
+			},
+		},
+		Return: function.ObjectReturn{
+			AttributeTypes: parsedPreAuthKeyAttributeTypes,
+		},
+	}
+}
+
+func (f *ParsePreAuthKeyFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var key string
+
+	resp.Error = function.ConcatFuncErrors(req.Arguments.Get(ctx, &key))
+	if resp.Error != nil {
+		return
+	}
+
+	parsed, err := parsePreAuthKey(key)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(0, err.Error())
+		return
+	}
+	prefix := types.StringNull()
+	if parsed.prefix != "" {
+		prefix = types.StringValue(parsed.prefix)
+	}
+	result, diags := types.ObjectValue(parsedPreAuthKeyAttributeTypes, map[string]attr.Value{
+		"format": types.StringValue(parsed.format),
+		"prefix": prefix,
+	})
+	resp.Error = function.FuncErrorFromDiags(ctx, diags)
+	if resp.Error != nil {
+		return
+	}
+
+	resp.Error = function.ConcatFuncErrors(resp.Result.Set(ctx, result))
+}
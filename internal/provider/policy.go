@@ -0,0 +1,122 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tailscale/hujson"
+)
+
+// policyObjectSections are top level sections of headscale policy that are json objects.
+var policyObjectSections = []string{"groups", "hosts", "tagOwners", "autoApprovers"}
+
+// policyListSections are top level sections of headscale policy that are json arrays.
+var policyListSections = []string{"acls", "ssh"}
+
+// parsePolicy parses headscale policy in HuJSON (json with comments and trailing commas)
+// and checks shape of known sections.
+func parsePolicy(policy string) (map[string]any, error) {
+	ast, err := hujson.Parse([]byte(policy))
+	if err != nil {
+		return nil, fmt.Errorf("parsing HuJSON: %w", err)
+	}
+	ast.Standardize()
+
+	decoder := json.NewDecoder(bytes.NewReader(ast.Pack()))
+	decoder.UseNumber()
+	var parsed any
+	if err := decoder.Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("parsing policy: %w", err)
+	}
+	result, ok := parsed.(map[string]any)
+	if !ok {
+		return nil, errors.New("policy must be json object")
+	}
+
+	for _, section := range policyObjectSections {
+		if value, ok := result[section]; ok {
+			if _, ok := value.(map[string]any); !ok {
+				return nil, fmt.Errorf("policy section %q must be json object", section)
+			}
+		}
+	}
+	for _, section := range policyListSections {
+		if value, ok := result[section]; ok {
+			if _, ok := value.([]any); !ok {
+				return nil, fmt.Errorf("policy section %q must be json array", section)
+			}
+		}
+	}
+	if tagOwners, ok := result["tagOwners"].(map[string]any); ok {
+		for tag := range tagOwners {
+			if err := checkTag(tag); err != nil {
+				return nil, fmt.Errorf("policy section \"tagOwners\": %w, got: %s", err, tag)
+			}
+		}
+	}
+	return result, nil
+}
+
+// canonicalPolicy returns policy as json with sorted keys and two spaces indentation,
+// so equal policies have equal text.
+func canonicalPolicy(policy map[string]any) (string, error) {
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(policy); err != nil {
+		return "", fmt.Errorf("encoding policy: %w", err)
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// mergePolicies merges policy b into policy a:
+// objects are merged recursively, arrays are concatenated without duplicates,
+// other values of b replace values of a.
+func mergePolicies(a, b map[string]any) map[string]any {
+	result := make(map[string]any, len(a)+len(b))
+	for key, value := range a {
+		result[key] = value
+	}
+	for key, value := range b {
+		result[key] = mergePolicyValues(result[key], value)
+	}
+	return result
+}
+
+func mergePolicyValues(a, b any) any {
+	switch bValue := b.(type) {
+	case map[string]any:
+		if aValue, ok := a.(map[string]any); ok {
+			return mergePolicies(aValue, bValue)
+		}
+	case []any:
+		if aValue, ok := a.([]any); ok {
+			return mergePolicyLists(aValue, bValue)
+		}
+	}
+	return b
+}
+
+func mergePolicyLists(a, b []any) []any {
+	result := make([]any, 0, len(a)+len(b))
+	seen := map[string]bool{}
+	for _, value := range append(append([]any{}, a...), b...) {
+		// maps are encoded with sorted keys, so equal values have equal json
+		encoded, err := json.Marshal(value)
+		if err == nil {
+			if seen[string(encoded)] {
+				continue
+			}
+			seen[string(encoded)] = true
+		}
+		result = append(result, value)
+	}
+	return result
+}
@@ -0,0 +1,63 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ function.Function = &PolicyCanonicalizeFunction{}
+
+func NewPolicyCanonicalizeFunction() function.Function {
+	return &PolicyCanonicalizeFunction{}
+}
+
+// PolicyCanonicalizeFunction defines the function implementation.
+type PolicyCanonicalizeFunction struct{}
+
+func (f *PolicyCanonicalizeFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "policy_canonicalize"
+}
+
+func (f *PolicyCanonicalizeFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:             "Canonicalize headscale policy",
+		MarkdownDescription: "Converts headscale policy from HuJSON (json with comments and trailing commas) to json with sorted keys and two spaces indentation, so formatting changes do not produce diffs.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:                "hujson",
+				MarkdownDescription: "Headscale policy in HuJSON.",
+				Validators: []function.StringParameterValidator{
+					policyValidator(),
+				},
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+func (f *PolicyCanonicalizeFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var hujson string
+
+	resp.Error = function.ConcatFuncErrors(req.Arguments.Get(ctx, &hujson))
+	if resp.Error != nil {
+		return
+	}
+
+	policy, err := parsePolicy(hujson)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(0, err.Error())
+		return
+	}
+	result, err := canonicalPolicy(policy)
+	if err != nil {
+		resp.Error = function.NewFuncError(err.Error())
+		return
+	}
+
+	resp.Error = function.ConcatFuncErrors(resp.Result.Set(ctx, result))
+}
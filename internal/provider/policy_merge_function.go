@@ -0,0 +1,75 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ function.Function = &PolicyMergeFunction{}
+
+func NewPolicyMergeFunction() function.Function {
+	return &PolicyMergeFunction{}
+}
+
+// PolicyMergeFunction defines the function implementation.
+type PolicyMergeFunction struct{}
+
+func (f *PolicyMergeFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "policy_merge"
+}
+
+func (f *PolicyMergeFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:             "Merge headscale policies",
+		MarkdownDescription: "Merges policy `b` into policy `a` and returns canonical json: objects (`groups`, `hosts`, `tagOwners`, ...) are merged recursively, arrays (`acls`, `ssh`, group members, ...) are concatenated without duplicates, other values of `b` replace values of `a`.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:                "a",
+				MarkdownDescription: "Headscale policy in HuJSON.",
+				Validators: []function.StringParameterValidator{
+					policyValidator(),
+				},
+			},
+			function.StringParameter{
+				Name:                "b",
+				MarkdownDescription: "Headscale policy in HuJSON.",
+				Validators: []function.StringParameterValidator{
+					policyValidator(),
+				},
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+func (f *PolicyMergeFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var a, b string
+
+	resp.Error = function.ConcatFuncErrors(req.Arguments.Get(ctx, &a, &b))
+	if resp.Error != nil {
+		return
+	}
+
+	aPolicy, err := parsePolicy(a)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(0, err.Error())
+		return
+	}
+	bPolicy, err := parsePolicy(b)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(1, err.Error())
+		return
+	}
+	result, err := canonicalPolicy(mergePolicies(aPolicy, bPolicy))
+	if err != nil {
+		resp.Error = function.NewFuncError(err.Error())
+		return
+	}
+
+	resp.Error = function.ConcatFuncErrors(resp.Result.Set(ctx, result))
+}
@@ -0,0 +1,141 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestPolicyMergeFunction(t *testing.T) {
+	testCases := []struct {
+		name     string
+		a        string
+		b        string
+		expected string
+		error    bool
+	}{
+		{
+			name: "sections are merged",
+			a: `{
+				// admins
+				"groups": {"group:admins": ["alice@"]},
+				"acls": [{"action": "accept", "src": ["group:admins"], "dst": ["*:*"]}],
+			}`,
+			b: `{
+				"groups": {"group:admins": ["bob@"], "group:dev": ["carol@"]},
+				"tagOwners": {"tag:ci": ["group:admins"]},
+				"acls": [{"action": "accept", "src": ["group:dev"], "dst": ["tag:ci:22"]}],
+			}`,
+			expected: `{
+  "acls": [
+    {
+      "action": "accept",
+      "dst": [
+        "*:*"
+      ],
+      "src": [
+        "group:admins"
+      ]
+    },
+    {
+      "action": "accept",
+      "dst": [
+        "tag:ci:22"
+      ],
+      "src": [
+        "group:dev"
+      ]
+    }
+  ],
+  "groups": {
+    "group:admins": [
+      "alice@",
+      "bob@"
+    ],
+    "group:dev": [
+      "carol@"
+    ]
+  },
+  "tagOwners": {
+    "tag:ci": [
+      "group:admins"
+    ]
+  }
+}`,
+		},
+		{
+			name: "duplicates are dropped",
+			a:    `{"acls": [{"action": "accept", "src": ["*"], "dst": ["*:*"]}]}`,
+			b:    `{"acls": [{"dst": ["*:*"], "src": ["*"], "action": "accept"}]}`,
+			expected: `{
+  "acls": [
+    {
+      "action": "accept",
+      "dst": [
+        "*:*"
+      ],
+      "src": [
+        "*"
+      ]
+    }
+  ]
+}`,
+		},
+		{
+			name: "scalars of b replace a",
+			a:    `{"randomizeClientPort": false, "hosts": {"db": "10.0.0.1/32"}}`,
+			b:    `{"randomizeClientPort": true, "hosts": {"db": "10.0.0.2/32"}}`,
+			expected: `{
+  "hosts": {
+    "db": "10.0.0.2/32"
+  },
+  "randomizeClientPort": true
+}`,
+		},
+		{
+			name:     "empty policies",
+			a:        `{}`,
+			b:        `{}`,
+			expected: `{}`,
+		},
+		{
+			name:  "invalid a",
+			a:     `{"acls": {}}`,
+			b:     `{}`,
+			error: true,
+		},
+		{
+			name:  "invalid tag owner of b",
+			a:     `{}`,
+			b:     `{"tagOwners": {"server": ["alice@"]}}`,
+			error: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			result, err := runFunction(
+				t,
+				NewPolicyMergeFunction(),
+				[]attr.Value{types.StringValue(testCase.a), types.StringValue(testCase.b)},
+				types.StringUnknown(),
+			)
+			if testCase.error {
+				if err == nil {
+					t.Fatalf("expected error, got %s", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !result.Equal(types.StringValue(testCase.expected)) {
+				t.Errorf("expected %s, got %s", testCase.expected, result)
+			}
+		})
+	}
+}
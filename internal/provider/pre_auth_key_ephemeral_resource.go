@@ -0,0 +1,250 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/setvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/paragor/terraform-provider-headscale/internal/headscaleclient"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ ephemeral.EphemeralResource = &PreAuthKeyEphemeralResource{}
+var _ ephemeral.EphemeralResourceWithConfigure = &PreAuthKeyEphemeralResource{}
+var _ ephemeral.EphemeralResourceWithClose = &PreAuthKeyEphemeralResource{}
+
+const preAuthKeyPrivateKey = "pre_auth_key"
+
+func NewPreAuthKeyEphemeralResource() ephemeral.EphemeralResource {
+	return &PreAuthKeyEphemeralResource{}
+}
+
+// PreAuthKeyEphemeralResource defines the ephemeral resource implementation.
+type PreAuthKeyEphemeralResource struct {
+	client headscaleclient.Client
+	cache  *snapshotCache
+}
+
+type PreAuthKeyEphemeralResourceModel struct {
+	UserId        types.Int64  `tfsdk:"user_id"`
+	User          types.String `tfsdk:"user"`
+	Reusable      types.Bool   `tfsdk:"reusable"`
+	Ephemeral     types.Bool   `tfsdk:"ephemeral"`
+	Ttl           types.String `tfsdk:"ttl"`
+	ACLTags       types.Set    `tfsdk:"acl_tags"`
+	ExpireOnClose types.Bool   `tfsdk:"expire_on_close"`
+
+	Id         types.Int64  `tfsdk:"id"`
+	CreatedAt  types.String `tfsdk:"created_at"`
+	Expiration types.String `tfsdk:"expiration"`
+	Key        types.String `tfsdk:"key"`
+}
+
+// preAuthKeyPrivate is private data of ephemeral pre auth key that is passed to Close.
+type preAuthKeyPrivate struct {
+	UserId        uint64 `json:"user_id"`
+	Key           string `json:"key"`
+	ExpireOnClose bool   `json:"expire_on_close"`
+}
+
+func (r *PreAuthKeyEphemeralResource) Metadata(ctx context.Context, req ephemeral.MetadataRequest, resp *ephemeral.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_pre_auth_key"
+}
+
+func (r *PreAuthKeyEphemeralResource) Schema(ctx context.Context, req ephemeral.SchemaRequest, resp *ephemeral.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		// This description is used by the documentation generator and the language server.
+		MarkdownDescription: "The ephemeral pre auth key creates short-lived pre auth key during terraform run, for example for cloud-init or instance user data. The key is never stored in state or plan files. By default keys are not reusable, not ephemeral, and expire in 1 hour. Terraform opens ephemeral resource on every plan and apply, so new key is created on every run.",
+
+		Attributes: map[string]schema.Attribute{
+			"user_id": schema.Int64Attribute{
+				MarkdownDescription: "User Id. Exactly one of `user_id` or `user` must be set.",
+				Optional:            true,
+				Computed:            true,
+			},
+			"user": schema.StringAttribute{
+				MarkdownDescription: "User name. Exactly one of `user_id` or `user` must be set.",
+				Optional:            true,
+				Computed:            true,
+				Validators: []validator.String{
+					stringvalidator.ExactlyOneOf(path.MatchRoot("user_id")),
+				},
+			},
+			"reusable": schema.BoolAttribute{
+				MarkdownDescription: "Define option for reuse pre auth key. Defaults to false",
+				Optional:            true,
+			},
+			"ephemeral": schema.BoolAttribute{
+				MarkdownDescription: "Define pre auth key as ephemeral. Defaults to false",
+				Optional:            true,
+			},
+			"ttl": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: `The time until the key expires. Valid time units are "ns", "us" (or "µs"), "ms", "s", "m", "h". Defaults to "1h"`,
+				Validators: []validator.String{
+					ttlValidator(),
+				},
+			},
+			"acl_tags": schema.SetAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "ACL tags on the pre auth key.",
+				Validators: []validator.Set{
+					setvalidator.ValueStringsAre(tagValidator()),
+				},
+			},
+			"expire_on_close": schema.BoolAttribute{
+				MarkdownDescription: "Expire the key when terraform closes ephemeral resource at the end of plan or apply. Enable it only if the key is used during the run, for example by provisioner. Defaults to false",
+				Optional:            true,
+			},
+
+			"id": schema.Int64Attribute{
+				Computed:            true,
+				MarkdownDescription: "ID of pre auth key",
+			},
+			"key": schema.StringAttribute{
+				Computed:    true,
+				Description: "The pre auth key.",
+				Sensitive:   true,
+			},
+			"expiration": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "expiration of pre auth key",
+			},
+			"created_at": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "time of creation pre auth key",
+			},
+		},
+	}
+}
+
+func (r *PreAuthKeyEphemeralResource) Configure(ctx context.Context, req ephemeral.ConfigureRequest, resp *ephemeral.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	config, ok := req.ProviderData.(*HeadscaleProviderConfiguration)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Ephemeral Resource Configure Type",
+			fmt.Sprintf("Expected *HeadscaleProviderConfiguration, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+
+		return
+	}
+
+	r.client = config.client
+	r.cache = config.cache
+}
+
+func (r *PreAuthKeyEphemeralResource) Open(ctx context.Context, req ephemeral.OpenRequest, resp *ephemeral.OpenResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_pre_auth_key", "open")
+	defer endOperation(&resp.Diagnostics)
+	var data PreAuthKeyEphemeralResourceModel
+
+	// Read Terraform config data into the model
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	aclTags := []string{}
+	resp.Diagnostics.Append(data.ACLTags.ElementsAs(ctx, &aclTags, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	expiration, err := preAuthKeyExpiration(data.Ttl)
+	if err != nil {
+		resp.Diagnostics.AddError("Parse TTL Error", fmt.Sprintf("Unable to parse ttl, got error: %s", err))
+		return
+	}
+	if data.UserId.IsNull() {
+		user, err := findUserByName(ctx, r.client, data.User.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("user"), "User Error", err.Error())
+			return
+		}
+		data.UserId = types.Int64Value(int64(user.GetId()))
+	}
+	response, err := r.client.CreatePreAuthKey(ctx, &v1.CreatePreAuthKeyRequest{
+		User:       uint64(data.UserId.ValueInt64()),
+		Reusable:   data.Reusable.ValueBool(),
+		Ephemeral:  data.Ephemeral.ValueBool(),
+		Expiration: expiration,
+		AclTags:    aclTags,
+	})
+	r.cache.InvalidatePreAuthKeys(uint64(data.UserId.ValueInt64()))
+	if err != nil {
+		addClientAttributeError(&resp.Diagnostics, path.Root("acl_tags"), "create pre auth key", err)
+		return
+	}
+
+	key := response.GetPreAuthKey()
+	data.Id = types.Int64Value(int64(key.GetId()))
+	data.Key = types.StringValue(key.GetKey())
+	data.CreatedAt = types.StringValue(key.GetCreatedAt().AsTime().Format(time.RFC3339))
+	data.Expiration = types.StringValue(key.GetExpiration().AsTime().Format(time.RFC3339))
+	if key.GetUser() != nil {
+		data.UserId = types.Int64Value(int64(key.GetUser().GetId()))
+		data.User = types.StringValue(key.GetUser().GetName())
+	}
+
+	private, err := json.Marshal(preAuthKeyPrivate{
+		UserId:        uint64(data.UserId.ValueInt64()),
+		Key:           key.GetKey(),
+		ExpireOnClose: data.ExpireOnClose.ValueBool(),
+	})
+	if err != nil {
+		resp.Diagnostics.AddError("Private Data Error", fmt.Sprintf("Unable to marshal private data, got error: %s", err))
+		return
+	}
+	resp.Diagnostics.Append(resp.Private.SetKey(ctx, preAuthKeyPrivateKey, private)...)
+
+	// Save data into ephemeral result data
+	resp.Diagnostics.Append(resp.Result.Set(ctx, &data)...)
+}
+
+func (r *PreAuthKeyEphemeralResource) Close(ctx context.Context, req ephemeral.CloseRequest, resp *ephemeral.CloseResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_pre_auth_key", "close")
+	defer endOperation(&resp.Diagnostics)
+	raw, diags := req.Private.GetKey(ctx, preAuthKeyPrivateKey)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() || raw == nil {
+		return
+	}
+
+	var private preAuthKeyPrivate
+	if err := json.Unmarshal(raw, &private); err != nil {
+		resp.Diagnostics.AddError("Private Data Error", fmt.Sprintf("Unable to unmarshal private data, got error: %s", err))
+		return
+	}
+	if !private.ExpireOnClose {
+		return
+	}
+
+	_, err := r.client.ExpirePreAuthKey(ctx, &v1.ExpirePreAuthKeyRequest{
+		User: private.UserId,
+		Key:  private.Key,
+	})
+	r.cache.InvalidatePreAuthKeys(private.UserId)
+	if err != nil {
+		addClientError(&resp.Diagnostics, "expire pre auth key", err)
+		return
+	}
+}
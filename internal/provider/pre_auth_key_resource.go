@@ -6,7 +6,6 @@ package provider
 import (
 	"context"
 	"fmt"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -25,12 +24,14 @@ import (
 	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/paragor/terraform-provider-headscale/internal/headscaleclient"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // Ensure provider defined types fully satisfy framework interfaces.
 var _ resource.Resource = &PreAuthKeyResource{}
 var _ resource.ResourceWithImportState = &PreAuthKeyResource{}
+var _ resource.ResourceWithUpgradeState = &PreAuthKeyResource{}
 
 func NewPreAuthKeyResource() resource.Resource {
 	return &PreAuthKeyResource{}
@@ -38,12 +39,14 @@ func NewPreAuthKeyResource() resource.Resource {
 
 // PreAuthKeyResource defines the resource implementation.
 type PreAuthKeyResource struct {
-	client v1.HeadscaleServiceClient
+	client headscaleclient.Client
+	cache  *snapshotCache
 }
 
 type PreAuthKeyResourceModel struct {
 	Id        types.Int64  `tfsdk:"id"`
 	UserId    types.Int64  `tfsdk:"user_id"`
+	User      types.String `tfsdk:"user"`
 	Reusable  types.Bool   `tfsdk:"reusable"`
 	Ephemeral types.Bool   `tfsdk:"ephemeral"`
 	Ttl       types.String `tfsdk:"ttl"`
@@ -62,6 +65,8 @@ func (r *PreAuthKeyResource) Metadata(ctx context.Context, req resource.Metadata
 
 func (r *PreAuthKeyResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
+		Version: preAuthKeyResourceVersion,
+
 		// This description is used by the documentation generator and the language server.
 		MarkdownDescription: "The pre auth key resource allows you to create a pre auth key that can be used to register a new device on the Headscale instance. By default keys that are created with this resource will be not reusable, not ephemeral, and expire in 1 hour. Keys cannot be modified, so any change to the input on this resource will cause the key to be expired and a new key to be created.",
 
@@ -74,10 +79,24 @@ func (r *PreAuthKeyResource) Schema(ctx context.Context, req resource.SchemaRequ
 				},
 			},
 			"user_id": schema.Int64Attribute{
-				MarkdownDescription: "User Id",
-				Required:            true,
+				MarkdownDescription: "User Id. Exactly one of `user_id` or `user` must be set.",
+				Optional:            true,
+				Computed:            true,
 				PlanModifiers: []planmodifier.Int64{
-					int64planmodifier.RequiresReplace(),
+					int64planmodifier.RequiresReplaceIfConfigured(),
+					int64planmodifier.UseStateForUnknown(),
+				},
+			},
+			"user": schema.StringAttribute{
+				MarkdownDescription: "User name. Exactly one of `user_id` or `user` must be set.",
+				Optional:            true,
+				Computed:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplaceIfConfigured(),
+					stringplanmodifier.UseStateForUnknown(),
+				},
+				Validators: []validator.String{
+					stringvalidator.ExactlyOneOf(path.MatchRoot("user_id")),
 				},
 			},
 			"reusable": schema.BoolAttribute{
@@ -106,7 +125,7 @@ func (r *PreAuthKeyResource) Schema(ctx context.Context, req resource.SchemaRequ
 					stringplanmodifier.RequiresReplace(),
 				},
 				Validators: []validator.String{
-					stringvalidator.RegexMatches(regexp.MustCompile(`^\d+(ns|us|µs|ms|s|m|h)$`), `Valid time units are "ns", "us" (or "µs"), "ms", "s", "m", "h"`),
+					ttlValidator(),
 				},
 			},
 			"acl_tags": schema.SetAttribute{
@@ -119,7 +138,7 @@ func (r *PreAuthKeyResource) Schema(ctx context.Context, req resource.SchemaRequ
 				},
 				Validators: []validator.Set{
 					setvalidator.ValueStringsAre(
-						stringvalidator.RegexMatches(regexp.MustCompile(`tag:[\w-]+`), "tag must follow scheme of `tag:<value>`"),
+						tagValidator(),
 					),
 				},
 			},
@@ -176,6 +195,7 @@ func (r *PreAuthKeyResource) Configure(ctx context.Context, req resource.Configu
 	}
 
 	r.client = config.client
+	r.cache = config.cache
 }
 
 func (r *PreAuthKeyResource) readComputedFields(key *v1.PreAuthKey, data *PreAuthKeyResourceModel) {
@@ -183,12 +203,30 @@ func (r *PreAuthKeyResource) readComputedFields(key *v1.PreAuthKey, data *PreAut
 	data.Expiration = types.StringValue(key.GetExpiration().AsTime().Format(time.RFC3339))
 	data.Key = types.StringValue(key.GetKey())
 	data.Id = types.Int64Value(int64(key.GetId()))
+	if key.GetUser() != nil {
+		data.UserId = types.Int64Value(int64(key.GetUser().GetId()))
+		data.User = types.StringValue(key.GetUser().GetName())
+	}
 	data.Expired = types.BoolValue(time.Now().After(key.Expiration.AsTime()))
 	data.Reusable = types.BoolValue(key.GetReusable())
 	data.Ephemeral = types.BoolValue(key.GetEphemeral())
 }
 
+// preAuthKeyExpiration returns expiration of pre auth key by ttl, default ttl is 1 hour.
+func preAuthKeyExpiration(ttl types.String) (*timestamppb.Timestamp, error) {
+	if ttl.IsNull() || ttl.IsUnknown() {
+		return timestamppb.New(time.Now().Add(1 * time.Hour)), nil
+	}
+	duration, err := time.ParseDuration(ttl.ValueString())
+	if err != nil {
+		return nil, err
+	}
+	return timestamppb.New(time.Now().Add(duration)), nil
+}
+
 func (r *PreAuthKeyResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_pre_auth_key", "create")
+	defer endOperation(&resp.Diagnostics)
 	var data PreAuthKeyResourceModel
 
 	// Read Terraform plan data into the model
@@ -205,16 +243,19 @@ func (r *PreAuthKeyResource) Create(ctx context.Context, req resource.CreateRequ
 		aclTags = append(aclTags, conv.ValueString())
 	}
 
-	var expiration *timestamppb.Timestamp
-	if data.Ttl.IsNull() {
-		expiration = timestamppb.New(time.Now().Add(1 * time.Hour))
-	} else {
-		ttl, err := time.ParseDuration(data.Ttl.ValueString())
+	expiration, err := preAuthKeyExpiration(data.Ttl)
+	if err != nil {
+		resp.Diagnostics.AddError("Parse TTL Error", fmt.Sprintf("Unable to parse ttl, got error: %s", err))
+		return
+	}
+	if data.UserId.IsUnknown() || data.UserId.IsNull() {
+		user, err := findUserByName(ctx, r.client, data.User.ValueString())
 		if err != nil {
-			resp.Diagnostics.AddError("Parse TTL Error", fmt.Sprintf("Unable to parse ttl, got error: %s", err))
+			resp.Diagnostics.AddAttributeError(path.Root("user"), "User Error", err.Error())
 			return
 		}
-		expiration = timestamppb.New(time.Now().Add(ttl))
+		data.UserId = types.Int64Value(int64(user.GetId()))
+		data.User = types.StringValue(user.GetName())
 	}
 	response, err := r.client.CreatePreAuthKey(ctx, &v1.CreatePreAuthKeyRequest{
 		User:       uint64(data.UserId.ValueInt64()),
@@ -223,8 +264,9 @@ func (r *PreAuthKeyResource) Create(ctx context.Context, req resource.CreateRequ
 		Expiration: expiration,
 		AclTags:    aclTags,
 	})
+	r.cache.InvalidatePreAuthKeys(uint64(data.UserId.ValueInt64()))
 	if err != nil {
-		resp.Diagnostics.AddError("Client Error", fmt.Sprintf("Unable to create pre auth key, got error: %s", err))
+		addClientAttributeError(&resp.Diagnostics, path.Root("acl_tags"), "create pre auth key", err)
 		return
 	}
 	r.readComputedFields(response.PreAuthKey, &data)
@@ -233,6 +275,8 @@ func (r *PreAuthKeyResource) Create(ctx context.Context, req resource.CreateRequ
 }
 
 func (r *PreAuthKeyResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_pre_auth_key", "read")
+	defer endOperation(&resp.Diagnostics)
 	var data PreAuthKeyResourceModel
 
 	// Read Terraform prior state data into the model
@@ -242,23 +286,17 @@ func (r *PreAuthKeyResource) Read(ctx context.Context, req resource.ReadRequest,
 		return
 	}
 
-	response, err := r.client.ListPreAuthKeys(ctx, &v1.ListPreAuthKeysRequest{User: uint64(data.UserId.ValueInt64())})
-	if err != nil {
-		resp.Diagnostics.AddError("Client Error", fmt.Sprintf("Unable to list pre auth keys, got error: %s", err))
+	preAuthKey, err := r.cache.PreAuthKey(ctx, uint64(data.UserId.ValueInt64()), uint64(data.Id.ValueInt64()))
+	if headscaleclient.IsNotFound(err) {
+		removeNotFoundResource(ctx, &resp.State, &resp.Diagnostics, fmt.Sprintf("user %d of pre auth key", data.UserId.ValueInt64()))
 		return
 	}
-
-	id := uint64(data.Id.ValueInt64())
-	var preAuthKey *v1.PreAuthKey
-	for _, found := range response.PreAuthKeys {
-		if found.Id != id {
-			continue
-		}
-		preAuthKey = found
+	if err != nil {
+		addClientError(&resp.Diagnostics, "list pre auth keys", err)
+		return
 	}
 	if preAuthKey == nil {
-		resp.Diagnostics.AddError("Pre auth key not found", "Pre auth key not found")
-		resp.State.RemoveResource(ctx)
+		removeNotFoundResource(ctx, &resp.State, &resp.Diagnostics, fmt.Sprintf("pre auth key %d", data.Id.ValueInt64()))
 		return
 	}
 	r.readComputedFields(preAuthKey, &data)
@@ -285,6 +323,8 @@ func (r *PreAuthKeyResource) Update(ctx context.Context, req resource.UpdateRequ
 }
 
 func (r *PreAuthKeyResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_pre_auth_key", "delete")
+	defer endOperation(&resp.Diagnostics)
 	var data PreAuthKeyResourceModel
 
 	// Read Terraform prior state data into the model
@@ -298,8 +338,9 @@ func (r *PreAuthKeyResource) Delete(ctx context.Context, req resource.DeleteRequ
 		User: uint64(data.UserId.ValueInt64()),
 		Key:  data.Key.ValueString(),
 	})
-	if err != nil {
-		resp.Diagnostics.AddError("Client Error", fmt.Sprintf("Unable to delete pre auth key, got error: %s", err))
+	r.cache.InvalidatePreAuthKeys(uint64(data.UserId.ValueInt64()))
+	if err != nil && !headscaleclient.IsNotFound(err) {
+		addClientError(&resp.Diagnostics, "delete pre auth key", err)
 		return
 	}
 }
@@ -310,16 +351,13 @@ func (r *PreAuthKeyResource) ImportState(ctx context.Context, req resource.Impor
 	if len(idParts) != 2 || idParts[0] == "" || idParts[1] == "" {
 		resp.Diagnostics.AddError(
 			"Unexpected Import Identifier",
-			fmt.Sprintf("Expected import identifier with format: user_id,key_id. Got: %q", req.ID),
+			fmt.Sprintf("Expected import identifier with format: user,key_id where user is id, name or email. Got: %q", req.ID),
 		)
 		return
 	}
-	userId, err := strconv.Atoi(idParts[0])
+	user, err := findUserByReference(ctx, r.client, idParts[0])
 	if err != nil {
-		resp.Diagnostics.AddError(
-			"Fail to parse user id",
-			fmt.Sprintf("Fail to parse user id: %s", err.Error()),
-		)
+		resp.Diagnostics.AddError("Fail to find user", err.Error())
 		return
 	}
 	keyId, err := strconv.Atoi(idParts[1])
@@ -330,6 +368,64 @@ func (r *PreAuthKeyResource) ImportState(ctx context.Context, req resource.Impor
 		)
 		return
 	}
-	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("user_id"), types.Int64Value(int64(userId)))...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("user_id"), types.Int64Value(int64(user.GetId())))...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("user"), types.StringValue(user.GetName()))...)
 	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), types.Int64Value(int64(keyId)))...)
 }
+
+// preAuthKeyResourceModelV0 is state of schema version 0, user was referenced only by required user_id.
+type preAuthKeyResourceModelV0 struct {
+	Id         types.Int64  `tfsdk:"id"`
+	UserId     types.Int64  `tfsdk:"user_id"`
+	Reusable   types.Bool   `tfsdk:"reusable"`
+	Ephemeral  types.Bool   `tfsdk:"ephemeral"`
+	Ttl        types.String `tfsdk:"ttl"`
+	ACLTags    types.Set    `tfsdk:"acl_tags"`
+	Expired    types.Bool   `tfsdk:"expired"`
+	CreatedAt  types.String `tfsdk:"created_at"`
+	Expiration types.String `tfsdk:"expiration"`
+	Key        types.String `tfsdk:"key"`
+}
+
+func (r *PreAuthKeyResource) UpgradeState(ctx context.Context) map[int64]resource.StateUpgrader {
+	return map[int64]resource.StateUpgrader{
+		0: {
+			PriorSchema: &schema.Schema{
+				Attributes: map[string]schema.Attribute{
+					"id":         schema.Int64Attribute{Computed: true},
+					"user_id":    schema.Int64Attribute{Required: true},
+					"reusable":   schema.BoolAttribute{Optional: true, Computed: true},
+					"ephemeral":  schema.BoolAttribute{Optional: true, Computed: true},
+					"ttl":        schema.StringAttribute{Optional: true},
+					"acl_tags":   schema.SetAttribute{Optional: true, Computed: true, ElementType: types.StringType},
+					"expired":    schema.BoolAttribute{Optional: true, Computed: true},
+					"key":        schema.StringAttribute{Computed: true, Sensitive: true},
+					"expiration": schema.StringAttribute{Optional: true, Computed: true},
+					"created_at": schema.StringAttribute{Computed: true},
+				},
+			},
+			StateUpgrader: func(ctx context.Context, req resource.UpgradeStateRequest, resp *resource.UpgradeStateResponse) {
+				var prior preAuthKeyResourceModelV0
+				resp.Diagnostics.Append(req.State.Get(ctx, &prior)...)
+				if resp.Diagnostics.HasError() {
+					return
+				}
+				// user name is filled by next read
+				data := PreAuthKeyResourceModel{
+					Id:         prior.Id,
+					UserId:     prior.UserId,
+					User:       types.StringNull(),
+					Reusable:   prior.Reusable,
+					Ephemeral:  prior.Ephemeral,
+					Ttl:        prior.Ttl,
+					ACLTags:    prior.ACLTags,
+					Expired:    prior.Expired,
+					CreatedAt:  upgradeTimestamp(prior.CreatedAt),
+					Expiration: upgradeTimestamp(prior.Expiration),
+					Key:        prior.Key,
+				}
+				resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+			},
+		},
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+)
+
+// TestUpgradeStateFromVersion0 upgrades states of schema version 0: states written by the first release of provider
+// and states edited by hand or by "terraform state push", for example with timestamps in local time zone or without id.
+func TestUpgradeStateFromVersion0(t *testing.T) {
+	testCases := []struct {
+		name     string
+		typeName string
+		resource resource.Resource
+		json     string
+		expected map[string]attr.Value
+	}{
+		{
+			name:     "user",
+			typeName: "headscale_user",
+			resource: NewUserResource(),
+			json:     `{"id":7,"name":"alice","display_name":null,"email":"alice@example.com","created_at":"2025-05-01T10:00:00Z"}`,
+			expected: map[string]attr.Value{
+				"id":           types.Int64Value(7),
+				"name":         types.StringValue("alice"),
+				"display_name": types.StringNull(),
+				"email":        types.StringValue("alice@example.com"),
+				"created_at":   types.StringValue("2025-05-01T10:00:00Z"),
+			},
+		},
+		{
+			name:     "user with local created_at",
+			typeName: "headscale_user",
+			resource: NewUserResource(),
+			json:     `{"id":7,"name":"alice","display_name":null,"email":null,"created_at":"2025-05-01T12:00:00.123456+02:00"}`,
+			expected: map[string]attr.Value{
+				"created_at": types.StringValue("2025-05-01T10:00:00Z"),
+			},
+		},
+		{
+			name:     "api key",
+			typeName: "headscale_api_key",
+			resource: NewApiKeyResource(),
+			json:     `{"id":"abcdefghij","ttl":"720h","expired":false,"key":"abcdefghij.secret","expiration":"2025-05-31T05:00:00-05:00","created_at":"2025-05-01T10:00:00Z"}`,
+			expected: map[string]attr.Value{
+				"id":         types.StringValue("abcdefghij"),
+				"ttl":        types.StringValue("720h"),
+				"key":        types.StringValue("abcdefghij.secret"),
+				"expiration": types.StringValue("2025-05-31T10:00:00Z"),
+				"created_at": types.StringValue("2025-05-01T10:00:00Z"),
+			},
+		},
+		{
+			name:     "pre auth key without user",
+			typeName: "headscale_pre_auth_key",
+			resource: NewPreAuthKeyResource(),
+			json:     `{"id":3,"user_id":1,"reusable":true,"ephemeral":false,"ttl":"1h","acl_tags":["tag:ci"],"expired":false,"key":"secret","expiration":"2025-05-01T13:00:00+02:00","created_at":"2025-05-01T10:00:00Z"}`,
+			expected: map[string]attr.Value{
+				"id":         types.Int64Value(3),
+				"user_id":    types.Int64Value(1),
+				"user":       types.StringNull(),
+				"reusable":   types.BoolValue(true),
+				"ephemeral":  types.BoolValue(false),
+				"acl_tags":   types.SetValueMust(types.StringType, []attr.Value{types.StringValue("tag:ci")}),
+				"key":        types.StringValue("secret"),
+				"expiration": types.StringValue("2025-05-01T11:00:00Z"),
+				"created_at": types.StringValue("2025-05-01T10:00:00Z"),
+			},
+		},
+		{
+			name:     "pre auth key with timestamp that is not parsed",
+			typeName: "headscale_pre_auth_key",
+			resource: NewPreAuthKeyResource(),
+			json:     `{"id":3,"user_id":1,"reusable":false,"ephemeral":false,"ttl":null,"acl_tags":[],"expired":false,"key":"secret","expiration":"2025-05-01 11:00:00 +0000 UTC","created_at":null}`,
+			expected: map[string]attr.Value{
+				"expiration": types.StringValue("2025-05-01 11:00:00 +0000 UTC"),
+				"created_at": types.StringNull(),
+			},
+		},
+		{
+			name:     "node tags",
+			typeName: "headscale_node_tags",
+			resource: NewNodeTagsResource(),
+			json:     `{"id":5,"node_id":5,"tags":["tag:a"]}`,
+			expected: map[string]attr.Value{
+				"id":      types.Int64Value(5),
+				"node_id": types.Int64Value(5),
+				"tags":    types.SetValueMust(types.StringType, []attr.Value{types.StringValue("tag:a")}),
+			},
+		},
+		{
+			name:     "node tags without id",
+			typeName: "headscale_node_tags",
+			resource: NewNodeTagsResource(),
+			json:     `{"id":null,"node_id":5,"tags":["tag:a"]}`,
+			expected: map[string]attr.Value{
+				"id": types.Int64Value(5),
+			},
+		},
+		{
+			name:     "node routes without id",
+			typeName: "headscale_node_routes",
+			resource: NewNodeRoutesResource(),
+			json:     `{"node_id":5,"routes":["10.0.0.0/24"]}`,
+			expected: map[string]attr.Value{
+				"id":      types.Int64Value(5),
+				"node_id": types.Int64Value(5),
+				"routes":  types.SetValueMust(types.StringType, []attr.Value{types.StringValue("10.0.0.0/24")}),
+			},
+		},
+	}
+
+	ctx := context.Background()
+	server, err := providerserver.NewProtocol6WithError(New("test")())()
+	if err != nil {
+		t.Fatalf("cant create provider server: %s", err)
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			resp, err := server.UpgradeResourceState(ctx, &tfprotov6.UpgradeResourceStateRequest{
+				TypeName: testCase.typeName,
+				Version:  0,
+				RawState: &tfprotov6.RawState{JSON: []byte(testCase.json)},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			for _, diagnostic := range resp.Diagnostics {
+				t.Fatalf("unexpected diagnostic: %s: %s", diagnostic.Summary, diagnostic.Detail)
+			}
+
+			schemaResp := &resource.SchemaResponse{}
+			testCase.resource.Schema(ctx, resource.SchemaRequest{}, schemaResp)
+			raw, err := resp.UpgradedState.Unmarshal(schemaResp.Schema.Type().TerraformType(ctx))
+			if err != nil {
+				t.Fatalf("cant decode upgraded state: %s", err)
+			}
+			if schemaResp.Schema.Version != 1 {
+				t.Errorf("expected schema version 1, got %d", schemaResp.Schema.Version)
+			}
+			state := tfsdk.State{Schema: schemaResp.Schema, Raw: raw}
+			for name, expected := range testCase.expected {
+				actual, failed := attributeOf(ctx, state, name, expected)
+				if failed {
+					t.Fatalf("cant get attribute %s", name)
+				}
+				if !actual.Equal(expected) {
+					t.Errorf("expected %s to be %s, got %s", name, expected, actual)
+				}
+			}
+		})
+	}
+}
+
+// attributeOf gets attribute of state as value of the same type as like.
+func attributeOf(ctx context.Context, state tfsdk.State, name string, like attr.Value) (attr.Value, bool) {
+	actual := like.Type(ctx).ValueType(ctx)
+	diags := state.GetAttribute(ctx, path.Root(name), &actual)
+	return actual, diags.HasError()
+}
@@ -0,0 +1,469 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/provider"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/paragor/terraform-provider-headscale/internal/headscaleclient"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestServerAPIFallback(t *testing.T) {
+	testCases := []struct {
+		name          string
+		serverVersion string
+		routes        headscaleclient.RoutesAPI
+		tags          headscaleclient.TagsAPI
+		warning       bool
+		error         bool
+	}{
+		{
+			name:          "known version is not detected",
+			serverVersion: "0.25.1",
+			routes:        headscaleclient.RoutesAPILegacy,
+			tags:          headscaleclient.TagsAPIForced,
+		},
+		{
+			name:    "failed detection without version assumes v0.26",
+			routes:  headscaleclient.RoutesAPIApproved,
+			tags:    headscaleclient.TagsAPIForced,
+			warning: true,
+		},
+		{
+			name:          "failed detection of unknown version does not guess",
+			serverVersion: "0.28.0",
+			routes:        headscaleclient.RoutesAPIUnknown,
+			tags:          headscaleclient.TagsAPIUnknown,
+			warning:       true,
+		},
+		{
+			name:          "unsupported version",
+			serverVersion: "0.22.0",
+			error:         true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var diags diag.Diagnostics
+			// api of fake client can not be detected
+			api := (&HeadscaleProvider{}).serverAPI(context.Background(), &fakeClient{}, testCase.serverVersion, &diags)
+			if testCase.error {
+				if !diags.HasError() {
+					t.Fatalf("expected error, got api %v", api)
+				}
+				return
+			}
+			if diags.HasError() {
+				t.Fatalf("unexpected errors: %v", diags)
+			}
+			if api.Routes != testCase.routes || api.Tags != testCase.tags {
+				t.Errorf("expected routes %q and tags %q, got %q and %q", testCase.routes, testCase.tags, api.Routes, api.Tags)
+			}
+			if (diags.WarningsCount() > 0) != testCase.warning {
+				t.Errorf("unexpected warnings: %v", diags)
+			}
+		})
+	}
+}
+
+// providerConfig returns configuration of provider with attributes, the other attributes are null.
+func providerConfig(t *testing.T, attributes map[string]func(tftypes.Type) tftypes.Value) tfsdk.Config {
+	t.Helper()
+	ctx := context.Background()
+	schemaResp := &provider.SchemaResponse{}
+	(&HeadscaleProvider{}).Schema(ctx, provider.SchemaRequest{}, schemaResp)
+	if schemaResp.Diagnostics.HasError() {
+		t.Fatalf("unexpected schema errors: %v", schemaResp.Diagnostics)
+	}
+	objectType := schemaResp.Schema.Type().TerraformType(ctx).(tftypes.Object)
+	return tfsdk.Config{Schema: schemaResp.Schema, Raw: objectOf(objectType, attributes)}
+}
+
+// objectOf returns object with attributes, the other attributes are null.
+func objectOf(objectType tftypes.Object, attributes map[string]func(tftypes.Type) tftypes.Value) tftypes.Value {
+	values := make(map[string]tftypes.Value, len(objectType.AttributeTypes))
+	for name, attributeType := range objectType.AttributeTypes {
+		values[name] = tftypes.NewValue(attributeType, nil)
+		if value, ok := attributes[name]; ok {
+			values[name] = value(attributeType)
+		}
+	}
+	return tftypes.NewValue(objectType, values)
+}
+
+func valueOf(value interface{}) func(tftypes.Type) tftypes.Value {
+	return func(attributeType tftypes.Type) tftypes.Value {
+		return tftypes.NewValue(attributeType, value)
+	}
+}
+
+func objectValueOf(attributes map[string]func(tftypes.Type) tftypes.Value) func(tftypes.Type) tftypes.Value {
+	return func(attributeType tftypes.Type) tftypes.Value {
+		return objectOf(attributeType.(tftypes.Object), attributes)
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	config := providerConfig(t, map[string]func(tftypes.Type) tftypes.Value{
+		"tls": objectValueOf(map[string]func(tftypes.Type) tftypes.Value{
+			"insecure": valueOf(true),
+			"ca_pem":   valueOf("ca"),
+		}),
+	})
+
+	var data HeadscaleProviderModel
+	if diags := config.Get(context.Background(), &data); diags.HasError() {
+		t.Fatalf("cant decode configuration: %v", diags)
+	}
+	if data.TLS == nil || !data.TLS.Insecure.ValueBool() || data.TLS.CaPem.ValueString() != "ca" || !data.TLS.ClientCertPem.IsNull() {
+		t.Errorf("unexpected tls configuration: %+v", data.TLS)
+	}
+}
+
+// bootstrapServer is headscale that creates api keys.
+type bootstrapServer struct {
+	v1.UnimplementedHeadscaleServiceServer
+
+	calls atomic.Int64
+}
+
+func (s *bootstrapServer) CreateApiKey(ctx context.Context, in *v1.CreateApiKeyRequest) (*v1.CreateApiKeyResponse, error) {
+	s.calls.Add(1)
+	return &v1.CreateApiKeyResponse{ApiKey: "bootstrap.secret"}, nil
+}
+
+// newBootstrapServer starts headscale that creates api keys on unix socket of temporary directory.
+func newBootstrapServer(t *testing.T) (*bootstrapServer, string) {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "headscale.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("cant listen unix socket: %s", err)
+	}
+	server := &bootstrapServer{}
+	grpcServer := grpc.NewServer()
+	v1.RegisterHeadscaleServiceServer(grpcServer, server)
+	go func() { _ = grpcServer.Serve(listener) }()
+	t.Cleanup(grpcServer.Stop)
+	return server, socket
+}
+
+func TestReadOnlyBootstrap(t *testing.T) {
+	testCases := []struct {
+		name   string
+		apiKey string
+		error  bool
+	}{
+		{name: "existing api key", apiKey: "existing.secret"},
+		{name: "missing api key", error: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			server, socket := newBootstrapServer(t)
+			outputFile := filepath.Join(t.TempDir(), "api_key")
+			if testCase.apiKey != "" {
+				if err := os.WriteFile(outputFile, []byte(testCase.apiKey+"\n"), 0o600); err != nil {
+					t.Fatalf("cant write output file: %s", err)
+				}
+			}
+			config := providerConfig(t, map[string]func(tftypes.Type) tftypes.Value{
+				"endpoint":       valueOf(socket),
+				"transport":      valueOf(transportUnix),
+				"server_version": valueOf("0.26.1"),
+				"read_only":      valueOf(true),
+				"bootstrap": objectValueOf(map[string]func(tftypes.Type) tftypes.Value{
+					"unix_socket": valueOf(socket),
+					"output_file": valueOf(outputFile),
+				}),
+			})
+			resp := &provider.ConfigureResponse{}
+			(&HeadscaleProvider{version: "test"}).Configure(context.Background(), provider.ConfigureRequest{Config: config}, resp)
+
+			if server.calls.Load() != 0 {
+				t.Errorf("expected api key not to be created in read only mode, got %d calls", server.calls.Load())
+			}
+			if testCase.error {
+				if !resp.Diagnostics.HasError() || !strings.Contains(resp.Diagnostics.Errors()[0].Detail(), "read only mode") {
+					t.Errorf("expected read only bootstrap error, got %v", resp.Diagnostics)
+				}
+				if _, err := os.Stat(outputFile); !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("expected output file not to be written, got error %v", err)
+				}
+				return
+			}
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("unexpected errors: %v", resp.Diagnostics)
+			}
+			configuration := resp.ResourceData.(*HeadscaleProviderConfiguration)
+			_, err := configuration.client.CreateApiKey(context.Background(), &v1.CreateApiKeyRequest{})
+			if !errors.Is(err, headscaleclient.ErrReadOnly) {
+				t.Errorf("expected read only error, got %v", err)
+			}
+		})
+	}
+}
+
+// credentialsServer is headscale that remembers api keys of requests.
+type credentialsServer struct {
+	v1.UnimplementedHeadscaleServiceServer
+
+	mu      sync.Mutex
+	apiKeys []string
+}
+
+func (s *credentialsServer) ListUsers(ctx context.Context, in *v1.ListUsersRequest) (*v1.ListUsersResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, authorization := range md.Get("authorization") {
+		s.apiKeys = append(s.apiKeys, strings.TrimPrefix(authorization, "Bearer "))
+	}
+	return &v1.ListUsersResponse{}, nil
+}
+
+func (s *credentialsServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.apiKeys)
+}
+
+// newCredentialsServer starts headscale that remembers api keys on unix socket of temporary directory.
+func newCredentialsServer(t *testing.T) (*credentialsServer, string) {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "headscale.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("cant listen unix socket: %s", err)
+	}
+	server := &credentialsServer{}
+	grpcServer := grpc.NewServer()
+	v1.RegisterHeadscaleServiceServer(grpcServer, server)
+	go func() { _ = grpcServer.Serve(listener) }()
+	t.Cleanup(grpcServer.Stop)
+	return server, socket
+}
+
+// unsetConnectionEnvs hides connection envs of environment from test.
+func unsetConnectionEnvs(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{
+		"HEADSCALE_ENDPOINT", "HEADSCALE_ENDPOINTS", "HEADSCALE_TRANSPORT", "HEADSCALE_API_KEY",
+		"HEADSCALE_ALLOW_INSECURE_PLAINTEXT", "HEADSCALE_TLS_INSECURE", "HEADSCALE_CONFIG",
+		"HEADSCALE_CLI_ADDRESS", "HEADSCALE_CLI_API_KEY", "HEADSCALE_CLI_INSECURE",
+	} {
+		t.Setenv(name, "")
+	}
+}
+
+func TestConnectionPrecedence(t *testing.T) {
+	attributeServer, attributeSocket := newCredentialsServer(t)
+	envServer, envSocket := newCredentialsServer(t)
+	fileServer, fileSocket := newCredentialsServer(t)
+	configFile := func(apiKey string) string {
+		return fmt.Sprintf("unix_socket: %s\ncli:\n  api_key: %s\n", fileSocket, apiKey)
+	}
+
+	testCases := []struct {
+		name       string
+		attributes map[string]func(tftypes.Type) tftypes.Value
+		envs       map[string]string
+		configFile string
+		server     *credentialsServer
+		apiKey     string
+	}{
+		{
+			name: "provider attributes",
+			attributes: map[string]func(tftypes.Type) tftypes.Value{
+				"endpoint":  valueOf(attributeSocket),
+				"transport": valueOf(transportUnix),
+				"api_key":   valueOf("attribute.secret"),
+			},
+			envs: map[string]string{
+				"HEADSCALE_ENDPOINT":    envSocket,
+				"HEADSCALE_TRANSPORT":   transportPlaintext,
+				"HEADSCALE_API_KEY":     "env.secret",
+				"HEADSCALE_CLI_API_KEY": "cli.secret",
+			},
+			configFile: configFile("file.secret"),
+			server:     attributeServer,
+			apiKey:     "attribute.secret",
+		},
+		{
+			name: "envs of provider",
+			envs: map[string]string{
+				"HEADSCALE_ENDPOINT":    envSocket,
+				"HEADSCALE_TRANSPORT":   transportUnix,
+				"HEADSCALE_API_KEY":     "env.secret",
+				"HEADSCALE_CLI_API_KEY": "cli.secret",
+			},
+			// address of CLI would switch transport to tls
+			configFile: configFile("file.secret") + "  address: headscale.example.com:443\n",
+			server:     envServer,
+			apiKey:     "env.secret",
+		},
+		{
+			name:       "envs of headscale CLI",
+			envs:       map[string]string{"HEADSCALE_CLI_API_KEY": "cli.secret"},
+			configFile: configFile("file.secret"),
+			server:     fileServer,
+			apiKey:     "cli.secret",
+		},
+		{
+			name:       "headscale config file",
+			configFile: configFile("file.secret"),
+			server:     fileServer,
+			apiKey:     "file.secret",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			unsetConnectionEnvs(t)
+			for name, value := range testCase.envs {
+				t.Setenv(name, value)
+			}
+			configPath := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(configPath, []byte(testCase.configFile), 0o600); err != nil {
+				t.Fatalf("cant write config file: %s", err)
+			}
+			t.Setenv("HEADSCALE_CONFIG", configPath)
+
+			attributes := map[string]func(tftypes.Type) tftypes.Value{"server_version": valueOf("0.26.1")}
+			maps.Copy(attributes, testCase.attributes)
+			resp := &provider.ConfigureResponse{}
+			(&HeadscaleProvider{version: "test"}).Configure(context.Background(), provider.ConfigureRequest{Config: providerConfig(t, attributes)}, resp)
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("unexpected errors: %v", resp.Diagnostics)
+			}
+
+			servers := []*credentialsServer{attributeServer, envServer, fileServer}
+			before := make([]int, len(servers))
+			for i, server := range servers {
+				before[i] = len(server.received())
+			}
+			configuration := resp.ResourceData.(*HeadscaleProviderConfiguration)
+			if _, err := configuration.client.ListUsers(context.Background(), &v1.ListUsersRequest{}); err != nil {
+				t.Fatalf("cant list users: %s", err)
+			}
+			for i, server := range servers {
+				received := server.received()[before[i]:]
+				if server != testCase.server {
+					if len(received) != 0 {
+						t.Errorf("expected only one server to be called, server %d received %v", i, received)
+					}
+					continue
+				}
+				if !slices.Equal(received, []string{testCase.apiKey}) {
+					t.Errorf("expected api key %q, got %v", testCase.apiKey, received)
+				}
+			}
+		})
+	}
+}
+
+func TestTLSInsecurePrecedence(t *testing.T) {
+	testCases := []struct {
+		name       string
+		attribute  *bool
+		envs       map[string]string
+		configFile string
+		insecure   bool
+	}{
+		{
+			name:       "provider attribute",
+			attribute:  new(bool),
+			envs:       map[string]string{"HEADSCALE_TLS_INSECURE": "true", "HEADSCALE_CLI_INSECURE": "true"},
+			configFile: "cli:\n  insecure: true\n",
+			insecure:   false,
+		},
+		{
+			name:       "env of provider",
+			envs:       map[string]string{"HEADSCALE_TLS_INSECURE": "false", "HEADSCALE_CLI_INSECURE": "true"},
+			configFile: "cli:\n  insecure: true\n",
+			insecure:   false,
+		},
+		{
+			name:       "env of headscale CLI",
+			envs:       map[string]string{"HEADSCALE_CLI_INSECURE": "false"},
+			configFile: "cli:\n  insecure: true\n",
+			insecure:   false,
+		},
+		{
+			name:       "headscale config file",
+			configFile: "cli:\n  insecure: true\n",
+			insecure:   true,
+		},
+		{
+			name:     "default",
+			insecure: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			unsetConnectionEnvs(t)
+			for name, value := range testCase.envs {
+				t.Setenv(name, value)
+			}
+			configPath := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(configPath, []byte(testCase.configFile), 0o600); err != nil {
+				t.Fatalf("cant write config file: %s", err)
+			}
+			cliConfig, err := loadHeadscaleCLIConfig(configPath)
+			if err != nil {
+				t.Fatalf("cant load headscale config: %s", err)
+			}
+
+			attributes := map[string]func(tftypes.Type) tftypes.Value{}
+			if testCase.attribute != nil {
+				attributes["tls"] = objectValueOf(map[string]func(tftypes.Type) tftypes.Value{"insecure": valueOf(*testCase.attribute)})
+			}
+			var data HeadscaleProviderModel
+			diags := providerConfig(t, attributes).Get(context.Background(), &data)
+			tlsConfig := (&HeadscaleProvider{}).tlsConfig(data, cliConfig, &diags)
+			if diags.HasError() {
+				t.Fatalf("unexpected errors: %v", diags)
+			}
+			if tlsConfig.InsecureSkipVerify != testCase.insecure {
+				t.Errorf("expected insecure %t, got %t", testCase.insecure, tlsConfig.InsecureSkipVerify)
+			}
+		})
+	}
+}
+
+func TestInvalidAllowInsecurePlaintextEnv(t *testing.T) {
+	unsetConnectionEnvs(t)
+	t.Setenv("HEADSCALE_ALLOW_INSECURE_PLAINTEXT", "yes please")
+	config := providerConfig(t, map[string]func(tftypes.Type) tftypes.Value{
+		"endpoint":  valueOf("127.0.0.1:50443"),
+		"transport": valueOf(transportPlaintext),
+	})
+	resp := &provider.ConfigureResponse{}
+	(&HeadscaleProvider{version: "test"}).Configure(context.Background(), provider.ConfigureRequest{Config: config}, resp)
+
+	errs := resp.Diagnostics.Errors()
+	if len(errs) != 1 || errs[0].Summary() != "Fail to parse env HEADSCALE_ALLOW_INSECURE_PLAINTEXT" {
+		t.Errorf("expected only error of env, got %v", resp.Diagnostics)
+	}
+}
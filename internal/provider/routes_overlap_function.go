@@ -0,0 +1,90 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ function.Function = &RoutesOverlapFunction{}
+
+func NewRoutesOverlapFunction() function.Function {
+	return &RoutesOverlapFunction{}
+}
+
+// RoutesOverlapFunction defines the function implementation.
+type RoutesOverlapFunction struct{}
+
+func (f *RoutesOverlapFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "routes_overlap"
+}
+
+func (f *RoutesOverlapFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:             "Check if routes overlap",
+		MarkdownDescription: "Returns true if any route prefix of the first list overlaps any route prefix of the second list, for example `10.0.0.0/8` and `10.1.0.0/16`.",
+		Parameters: []function.Parameter{
+			function.ListParameter{
+				Name:                "a",
+				ElementType:         types.StringType,
+				MarkdownDescription: "Route prefixes, for example `[\"10.0.0.0/8\"]`.",
+			},
+			function.ListParameter{
+				Name:                "b",
+				ElementType:         types.StringType,
+				MarkdownDescription: "Route prefixes, for example `[\"10.1.0.0/16\"]`.",
+			},
+		},
+		Return: function.BoolReturn{},
+	}
+}
+
+func (f *RoutesOverlapFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var a, b []string
+
+	resp.Error = function.ConcatFuncErrors(req.Arguments.Get(ctx, &a, &b))
+	if resp.Error != nil {
+		return
+	}
+
+	aPrefixes, funcErr := parseRoutesArgument(0, a)
+	if funcErr != nil {
+		resp.Error = funcErr
+		return
+	}
+	bPrefixes, funcErr := parseRoutesArgument(1, b)
+	if funcErr != nil {
+		resp.Error = funcErr
+		return
+	}
+
+	overlap := false
+	for _, aPrefix := range aPrefixes {
+		for _, bPrefix := range bPrefixes {
+			if aPrefix.Overlaps(bPrefix) {
+				overlap = true
+			}
+		}
+	}
+
+	resp.Error = function.ConcatFuncErrors(resp.Result.Set(ctx, overlap))
+}
+
+func parseRoutesArgument(position int64, routes []string) ([]netip.Prefix, *function.FuncError) {
+	prefixes := make([]netip.Prefix, 0, len(routes))
+	for _, route := range routes {
+		prefix, err := parseRoute(route)
+		if err != nil {
+			return nil, function.NewArgumentFuncError(position, fmt.Sprintf("Invalid value %q: %s", route, err))
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
@@ -0,0 +1,58 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestRoutesOverlapFunction(t *testing.T) {
+	testCases := []struct {
+		name     string
+		a        []string
+		b        []string
+		expected bool
+		error    string
+	}{
+		{name: "subnet", a: []string{"10.0.0.0/8"}, b: []string{"10.1.0.0/16"}, expected: true},
+		{name: "supernet", a: []string{"10.1.0.0/16"}, b: []string{"10.0.0.0/8"}, expected: true},
+		{name: "equal", a: []string{"192.168.0.0/24"}, b: []string{"192.168.0.0/24"}, expected: true},
+		{name: "disjoint", a: []string{"10.0.0.0/8"}, b: []string{"192.168.0.0/16"}, expected: false},
+		{name: "adjacent", a: []string{"10.0.0.0/24"}, b: []string{"10.0.1.0/24"}, expected: false},
+		{name: "any of lists", a: []string{"172.16.0.0/12", "10.0.0.0/8"}, b: []string{"192.168.0.0/16", "10.2.3.0/24"}, expected: true},
+		{name: "exit node", a: []string{"0.0.0.0/0"}, b: []string{"8.8.8.0/24"}, expected: true},
+		{name: "ipv4 and ipv6", a: []string{"0.0.0.0/0"}, b: []string{"::/0"}, expected: false},
+		{name: "ipv6", a: []string{"fd7a:115c:a1e0::/48"}, b: []string{"fd7a:115c:a1e0:ab12::/64"}, expected: true},
+		{name: "empty", a: []string{}, b: []string{"10.0.0.0/8"}, expected: false},
+		{name: "invalid a", a: []string{"10.0.0.0"}, b: []string{"10.0.0.0/8"}, error: `"10.0.0.0"`},
+		{name: "invalid b", a: []string{"10.0.0.0/8"}, b: []string{"10.0.0.0/8", "nope"}, error: `"nope"`},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			result, err := runFunction(
+				t,
+				NewRoutesOverlapFunction(),
+				[]attr.Value{stringList(testCase.a...), stringList(testCase.b...)},
+				types.BoolUnknown(),
+			)
+			if testCase.error != "" {
+				if err == nil || !strings.Contains(err.Error(), testCase.error) {
+					t.Fatalf("expected error with %s, got result %s, error %v", testCase.error, result, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !result.Equal(types.BoolValue(testCase.expected)) {
+				t.Errorf("expected %t, got %s", testCase.expected, result)
+			}
+		})
+	}
+}
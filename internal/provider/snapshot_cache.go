@@ -0,0 +1,229 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/paragor/terraform-provider-headscale/internal/headscaleclient"
+	"golang.org/x/sync/singleflight"
+)
+
+// snapshotLoadTimeout limits shared load of snapshot, it does not follow deadline of callers.
+const snapshotLoadTimeout = time.Minute
+
+// snapshotCache keeps snapshots of headscale objects during one terraform run,
+// so refresh of many resources costs one List call instead of one call per resource.
+// Concurrent loads of the same snapshot are merged via singleflight.
+// Resources must invalidate snapshot after they change objects in it.
+type snapshotCache struct {
+	client headscaleclient.Client
+	group  singleflight.Group
+	// loadTimeout limits shared load, so hung load does not block callers without deadline forever.
+	loadTimeout time.Duration
+
+	mu sync.Mutex
+	// generation is changed on every invalidation, so snapshots that were loaded
+	// before invalidation are not stored and not shared via singleflight.
+	generation uint64
+	// nodes is nil if snapshot is not loaded.
+	nodes map[uint64]*v1.Node
+	// users is nil if snapshot is not loaded.
+	users map[uint64]*v1.User
+	// preAuthKeys are snapshots of pre auth keys by user id.
+	preAuthKeys map[uint64]map[uint64]*v1.PreAuthKey
+}
+
+func newSnapshotCache(client headscaleclient.Client) *snapshotCache {
+	return &snapshotCache{
+		client:      client,
+		loadTimeout: snapshotLoadTimeout,
+		preAuthKeys: map[uint64]map[uint64]*v1.PreAuthKey{},
+	}
+}
+
+// Nodes returns all nodes sorted by id.
+func (c *snapshotCache) Nodes(ctx context.Context) ([]*v1.Node, error) {
+	nodes, err := c.loadNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*v1.Node, 0, len(nodes))
+	for _, node := range nodes {
+		result = append(result, node)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].GetId() < result[j].GetId()
+	})
+	return result, nil
+}
+
+// Node returns node by id or nil if node is not found.
+func (c *snapshotCache) Node(ctx context.Context, id uint64) (*v1.Node, error) {
+	nodes, err := c.loadNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return nodes[id], nil
+}
+
+// User returns user by id or nil if user is not found.
+func (c *snapshotCache) User(ctx context.Context, id uint64) (*v1.User, error) {
+	users, err := c.loadUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return users[id], nil
+}
+
+// PreAuthKey returns pre auth key of user by id or nil if key is not found.
+func (c *snapshotCache) PreAuthKey(ctx context.Context, userId uint64, id uint64) (*v1.PreAuthKey, error) {
+	keys, err := c.loadPreAuthKeys(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	return keys[id], nil
+}
+
+func (c *snapshotCache) InvalidateNodes() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.nodes = nil
+}
+
+func (c *snapshotCache) InvalidateUsers() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.users = nil
+	// nodes and pre auth keys contain user
+	c.nodes = nil
+	c.preAuthKeys = map[uint64]map[uint64]*v1.PreAuthKey{}
+}
+
+func (c *snapshotCache) InvalidatePreAuthKeys(userId uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	delete(c.preAuthKeys, userId)
+}
+
+// do runs load once for all concurrent callers of key.
+// Load is shared, so it runs without cancellation and deadline of the first caller but with own timeout,
+// cancelled caller stops waiting for it without failing other callers.
+func (c *snapshotCache) do(ctx context.Context, key string, load func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	resultCh := c.group.DoChan(key, func() (interface{}, error) {
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.loadTimeout)
+		defer cancel()
+		return load(loadCtx)
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-resultCh:
+		return result.Val, result.Err
+	}
+}
+
+func (c *snapshotCache) loadNodes(ctx context.Context) (map[uint64]*v1.Node, error) {
+	c.mu.Lock()
+	nodes := c.nodes
+	generation := c.generation
+	c.mu.Unlock()
+	if nodes != nil {
+		return nodes, nil
+	}
+
+	result, err := c.do(ctx, fmt.Sprintf("nodes/%d", generation), func(ctx context.Context) (interface{}, error) {
+		response, err := c.client.ListNodes(ctx, &v1.ListNodesRequest{})
+		if err != nil {
+			return nil, err
+		}
+		nodes := make(map[uint64]*v1.Node, len(response.GetNodes()))
+		for _, node := range response.GetNodes() {
+			nodes[node.GetId()] = node
+		}
+		c.mu.Lock()
+		if c.generation == generation {
+			c.nodes = nodes
+		}
+		c.mu.Unlock()
+		return nodes, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	//nolint:forcetypeassert
+	return result.(map[uint64]*v1.Node), nil
+}
+
+func (c *snapshotCache) loadUsers(ctx context.Context) (map[uint64]*v1.User, error) {
+	c.mu.Lock()
+	users := c.users
+	generation := c.generation
+	c.mu.Unlock()
+	if users != nil {
+		return users, nil
+	}
+
+	result, err := c.do(ctx, fmt.Sprintf("users/%d", generation), func(ctx context.Context) (interface{}, error) {
+		response, err := c.client.ListUsers(ctx, &v1.ListUsersRequest{})
+		if err != nil {
+			return nil, err
+		}
+		users := make(map[uint64]*v1.User, len(response.GetUsers()))
+		for _, user := range response.GetUsers() {
+			users[user.GetId()] = user
+		}
+		c.mu.Lock()
+		if c.generation == generation {
+			c.users = users
+		}
+		c.mu.Unlock()
+		return users, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	//nolint:forcetypeassert
+	return result.(map[uint64]*v1.User), nil
+}
+
+func (c *snapshotCache) loadPreAuthKeys(ctx context.Context, userId uint64) (map[uint64]*v1.PreAuthKey, error) {
+	c.mu.Lock()
+	keys, ok := c.preAuthKeys[userId]
+	generation := c.generation
+	c.mu.Unlock()
+	if ok {
+		return keys, nil
+	}
+
+	result, err := c.do(ctx, fmt.Sprintf("pre_auth_keys/%d/%d", userId, generation), func(ctx context.Context) (interface{}, error) {
+		response, err := c.client.ListPreAuthKeys(ctx, &v1.ListPreAuthKeysRequest{User: userId})
+		if err != nil {
+			return nil, err
+		}
+		keys := make(map[uint64]*v1.PreAuthKey, len(response.GetPreAuthKeys()))
+		for _, key := range response.GetPreAuthKeys() {
+			keys[key.GetId()] = key
+		}
+		c.mu.Lock()
+		if c.generation == generation {
+			c.preAuthKeys[userId] = keys
+		}
+		c.mu.Unlock()
+		return keys, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	//nolint:forcetypeassert
+	return result.(map[uint64]*v1.PreAuthKey), nil
+}
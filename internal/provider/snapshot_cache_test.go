@@ -0,0 +1,225 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+)
+
+// blockingNodes returns client whose ListNodes waits for release and returns node with id of call number.
+func blockingNodes(calls *atomic.Int64, started chan<- struct{}, release <-chan struct{}) *fakeClient {
+	return &fakeClient{
+		listNodes: func(ctx context.Context, _ *v1.ListNodesRequest) (*v1.ListNodesResponse, error) {
+			call := calls.Add(1)
+			if started != nil {
+				started <- struct{}{}
+			}
+			<-release
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			return &v1.ListNodesResponse{Nodes: []*v1.Node{{Id: uint64(call)}}}, nil
+		},
+	}
+}
+
+func TestSnapshotCacheSharesConcurrentLoads(t *testing.T) {
+	var calls atomic.Int64
+	release := make(chan struct{})
+	cache := newSnapshotCache(blockingNodes(&calls, nil, release))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			node, err := cache.Node(context.Background(), 1)
+			if err == nil && node == nil {
+				err = errors.New("node 1 is not found")
+			}
+			errs <- err
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected 1 ListNodes call, got %d", calls.Load())
+	}
+}
+
+func TestSnapshotCacheInvalidationDuringLoad(t *testing.T) {
+	var calls atomic.Int64
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	cache := newSnapshotCache(blockingNodes(&calls, started, release))
+
+	firstResult := make(chan []*v1.Node, 1)
+	go func() {
+		nodes, _ := cache.Nodes(context.Background())
+		firstResult <- nodes
+	}()
+	<-started
+
+	// snapshot that was loading during invalidation is stale, it must not be shared or stored
+	cache.InvalidateNodes()
+	secondResult := make(chan []*v1.Node, 1)
+	go func() {
+		nodes, _ := cache.Nodes(context.Background())
+		secondResult <- nodes
+	}()
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("load after invalidation joined load before invalidation")
+	}
+	close(release)
+
+	first, second := <-firstResult, <-secondResult
+	if len(first) != 1 || len(second) != 1 || first[0].GetId() == second[0].GetId() {
+		t.Fatalf("expected results of different loads, got %v and %v", first, second)
+	}
+	cached, err := cache.Nodes(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("expected 2 ListNodes calls, got %d", calls.Load())
+	}
+	if cached[0].GetId() != second[0].GetId() {
+		t.Errorf("expected snapshot of load after invalidation %d, got %d", second[0].GetId(), cached[0].GetId())
+	}
+}
+
+func TestSnapshotCacheCancelledCallerDoesNotFailOthers(t *testing.T) {
+	var calls atomic.Int64
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	cache := newSnapshotCache(blockingNodes(&calls, started, release))
+
+	firstCtx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := cache.Nodes(firstCtx)
+		firstErr <- err
+	}()
+	<-started
+
+	secondErr := make(chan error, 1)
+	go func() {
+		_, err := cache.Nodes(context.Background())
+		secondErr <- err
+	}()
+	cancel()
+	select {
+	case err := <-firstErr:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected cancelled caller to get context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelled caller waits for shared load")
+	}
+
+	close(release)
+	if err := <-secondErr; err != nil {
+		t.Errorf("cancellation of first caller failed other caller: %s", err)
+	}
+	if _, err := cache.Nodes(context.Background()); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected 1 ListNodes call, got %d", calls.Load())
+	}
+}
+
+func TestSnapshotCacheLoadTimeout(t *testing.T) {
+	var calls atomic.Int64
+	// ListNodes hangs until its context is done
+	cache := newSnapshotCache(&fakeClient{
+		listNodes: func(ctx context.Context, _ *v1.ListNodesRequest) (*v1.ListNodesResponse, error) {
+			calls.Add(1)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	})
+	cache.loadTimeout = 10 * time.Millisecond
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := cache.Nodes(context.Background())
+			errs <- err
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("expected deadline exceeded, got %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("caller waits for hung load")
+		}
+	}
+	// failed load is not stored, the next caller loads again
+	if _, err := cache.Nodes(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	if calls.Load() < 2 {
+		t.Errorf("expected load to be retried, got %d ListNodes calls", calls.Load())
+	}
+}
+
+func TestSnapshotCacheInvalidateUsers(t *testing.T) {
+	var userCalls, keyCalls atomic.Int64
+	cache := newSnapshotCache(&fakeClient{
+		listUsers: func(ctx context.Context, _ *v1.ListUsersRequest) (*v1.ListUsersResponse, error) {
+			userCalls.Add(1)
+			return &v1.ListUsersResponse{Users: []*v1.User{{Id: 1, Name: "alice"}}}, nil
+		},
+		listPreAuthKeys: func(ctx context.Context, in *v1.ListPreAuthKeysRequest) (*v1.ListPreAuthKeysResponse, error) {
+			keyCalls.Add(1)
+			return &v1.ListPreAuthKeysResponse{PreAuthKeys: []*v1.PreAuthKey{{Id: 2}}}, nil
+		},
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if user, err := cache.User(ctx, 1); err != nil || user.GetName() != "alice" {
+			t.Fatalf("unexpected user %v, error %v", user, err)
+		}
+		if key, err := cache.PreAuthKey(ctx, 1, 2); err != nil || key == nil {
+			t.Fatalf("unexpected pre auth key %v, error %v", key, err)
+		}
+	}
+	if userCalls.Load() != 1 || keyCalls.Load() != 1 {
+		t.Fatalf("expected snapshots to be reused, got %d ListUsers and %d ListPreAuthKeys calls", userCalls.Load(), keyCalls.Load())
+	}
+
+	cache.InvalidateUsers()
+	if _, err := cache.User(ctx, 1); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := cache.PreAuthKey(ctx, 1, 2); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if userCalls.Load() != 2 || keyCalls.Load() != 2 {
+		t.Errorf("expected invalidation of users to drop pre auth keys, got %d ListUsers and %d ListPreAuthKeys calls", userCalls.Load(), keyCalls.Load())
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// SSHTunnelModel describes ssh connection that is used to reach headscale.
+type SSHTunnelModel struct {
+	Host       types.String `tfsdk:"host"`
+	User       types.String `tfsdk:"user"`
+	PrivateKey types.String `tfsdk:"private_key"`
+	KnownHosts types.String `tfsdk:"known_hosts"`
+}
+
+// sshTunnelAttribute returns optional ssh tunnel block of provider schema.
+func sshTunnelAttribute(description string) schema.SingleNestedAttribute {
+	return schema.SingleNestedAttribute{
+		MarkdownDescription: description,
+		Optional:            true,
+		Attributes: map[string]schema.Attribute{
+			"host": schema.StringAttribute{
+				MarkdownDescription: "Address of ssh server, for example \"bastion.example.com\" or \"bastion.example.com:2222\". Default port is 22.",
+				Required:            true,
+			},
+			"user": schema.StringAttribute{
+				MarkdownDescription: "Ssh user.",
+				Required:            true,
+			},
+			"private_key": schema.StringAttribute{
+				MarkdownDescription: "Private key in PEM format. If it is not set, provider uses ssh agent from env \"SSH_AUTH_SOCK\".",
+				Optional:            true,
+				Sensitive:           true,
+			},
+			"known_hosts": schema.StringAttribute{
+				MarkdownDescription: "Content of known_hosts file to verify ssh server key. If it is not set, provider reads \"~/.ssh/known_hosts\".",
+				Optional:            true,
+			},
+		},
+	}
+}
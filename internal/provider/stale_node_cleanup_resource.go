@@ -0,0 +1,391 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/paragor/terraform-provider-headscale/internal/headscaleclient"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &StaleNodeCleanupResource{}
+var _ resource.ResourceWithModifyPlan = &StaleNodeCleanupResource{}
+
+const (
+	staleNodeActionExpire = "expire"
+	staleNodeActionDelete = "delete"
+)
+
+var staleNodeAttributeTypes = map[string]attr.Type{
+	"id":         types.Int64Type,
+	"name":       types.StringType,
+	"given_name": types.StringType,
+	"user":       types.StringType,
+	"last_seen":  types.StringType,
+}
+
+func NewStaleNodeCleanupResource() resource.Resource {
+	return &StaleNodeCleanupResource{}
+}
+
+// StaleNodeCleanupResource defines the resource implementation.
+type StaleNodeCleanupResource struct {
+	client headscaleclient.Client
+	cache  *snapshotCache
+}
+
+type StaleNodeCleanupResourceModel struct {
+	Id        types.String       `tfsdk:"id"`
+	Selector  *NodeSelectorModel `tfsdk:"selector"`
+	OlderThan types.String       `tfsdk:"older_than"`
+	Action    types.String       `tfsdk:"action"`
+	DryRun    types.Bool         `tfsdk:"dry_run"`
+	Nodes     types.List         `tfsdk:"nodes"`
+}
+
+type StaleNodeModel struct {
+	Id        types.Int64  `tfsdk:"id"`
+	Name      types.String `tfsdk:"name"`
+	GivenName types.String `tfsdk:"given_name"`
+	User      types.String `tfsdk:"user"`
+	LastSeen  types.String `tfsdk:"last_seen"`
+}
+
+func (r *StaleNodeCleanupResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_stale_node_cleanup"
+}
+
+func (r *StaleNodeCleanupResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		// This description is used by the documentation generator and the language server.
+		MarkdownDescription: "The resource expires or deletes offline nodes that are not seen for a long time, for example dead CI runners and VMs. " +
+			"On every plan the resource finds stale nodes and shows them in `nodes`, apply expires or deletes them. " +
+			"Already expired nodes are skipped by action `expire`. Apply checks every node again and skips nodes that came back online or were seen after plan. Destroying the resource does nothing with nodes.",
+
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "ID of resources",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"selector": nodeSelectorAttribute("Selector of nodes to clean up."),
+			"older_than": schema.StringAttribute{
+				Required:            true,
+				MarkdownDescription: `Node is stale if it is offline and it is last seen before this duration, for example "720h". Node that was never seen is stale if it is created before this duration, node without both times is never stale. Valid time units are "ns", "us" (or "µs"), "ms", "s", "m", "h".`,
+				Validators: []validator.String{
+					ttlValidator(),
+				},
+			},
+			"action": schema.StringAttribute{
+				Optional:            true,
+				Computed:            true,
+				MarkdownDescription: `What to do with stale nodes: "expire" or "delete". Defaults to "expire"`,
+				Default:             stringdefault.StaticString(staleNodeActionExpire),
+				Validators: []validator.String{
+					stringvalidator.OneOf(staleNodeActionExpire, staleNodeActionDelete),
+				},
+			},
+			"dry_run": schema.BoolAttribute{
+				Optional:            true,
+				Computed:            true,
+				MarkdownDescription: "Only show stale nodes in `nodes` without changes. Defaults to false",
+				Default:             booldefault.StaticBool(false),
+			},
+			"nodes": schema.ListNestedAttribute{
+				Computed:            true,
+				MarkdownDescription: "Stale nodes that are affected by the last apply.",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"id": schema.Int64Attribute{
+							Computed:    true,
+							Description: "The id of the device",
+						},
+						"name": schema.StringAttribute{
+							Computed:    true,
+							Description: "The device's name.",
+						},
+						"given_name": schema.StringAttribute{
+							Computed:    true,
+							Description: "The device's name in MagicDNS.",
+						},
+						"user": schema.StringAttribute{
+							Computed:    true,
+							Description: "The name of the user who owns the device.",
+						},
+						"last_seen": schema.StringAttribute{
+							Computed:    true,
+							Description: "The time when the device was seen last time, null if it was never seen.",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (r *StaleNodeCleanupResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	config, ok := req.ProviderData.(*HeadscaleProviderConfiguration)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Resource Configure Type",
+			fmt.Sprintf("Expected *HeadscaleProviderConfiguration, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+
+		return
+	}
+
+	r.client = config.client
+	r.cache = config.cache
+}
+
+func (r *StaleNodeCleanupResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	// provider is not configured yet or resource is destroyed
+	if r.client == nil || req.Plan.Raw.IsNull() {
+		return
+	}
+
+	if !req.Config.Raw.IsFullyKnown() {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("nodes"), types.ListUnknown(types.ObjectType{AttrTypes: staleNodeAttributeTypes}))...)
+		return
+	}
+	var data StaleNodeCleanupResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	nodes, diags := r.staleNodes(ctx, data)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("nodes"), nodes)...)
+}
+
+// staleNodes returns nodes that match selector and are offline longer than threshold.
+func (r *StaleNodeCleanupResource) staleNodes(ctx context.Context, data StaleNodeCleanupResourceModel) (types.List, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	empty := types.ListNull(types.ObjectType{AttrTypes: staleNodeAttributeTypes})
+
+	olderThan, err := time.ParseDuration(data.OlderThan.ValueString())
+	if err != nil {
+		diags.AddAttributeError(path.Root("older_than"), "Parse Duration Error", fmt.Sprintf("Unable to parse older_than, got error: %s", err))
+		return empty, diags
+	}
+	matches, err := data.Selector.nodeMatcher()
+	if err != nil {
+		diags.AddAttributeError(path.Root("selector"), "Selector Error", err.Error())
+		return empty, diags
+	}
+	nodes, err := r.cache.Nodes(ctx)
+	if err != nil {
+		addClientError(&diags, "list nodes", err)
+		return empty, diags
+	}
+
+	isStale := staleNodeMatcher(matches, olderThan, data.Action.ValueString())
+	stale := make([]StaleNodeModel, 0)
+	for _, node := range nodes {
+		if !isStale(node) {
+			continue
+		}
+		lastSeen := types.StringNull()
+		if node.GetLastSeen() != nil {
+			lastSeen = types.StringValue(node.GetLastSeen().AsTime().Format(time.RFC3339))
+		}
+		stale = append(stale, StaleNodeModel{
+			Id:        types.Int64Value(int64(node.GetId())),
+			Name:      types.StringValue(node.GetName()),
+			GivenName: types.StringValue(node.GetGivenName()),
+			User:      types.StringValue(node.GetUser().GetName()),
+			LastSeen:  lastSeen,
+		})
+	}
+	list, listDiags := types.ListValueFrom(ctx, types.ObjectType{AttrTypes: staleNodeAttributeTypes}, stale)
+	diags.Append(listDiags...)
+	return list, diags
+}
+
+// staleNodeMatcher returns func that reports whether node matches selector and is offline longer than olderThan.
+// Nodes that are already expired are not stale for action "expire".
+func staleNodeMatcher(matches func(node *v1.Node) bool, olderThan time.Duration, action string) func(node *v1.Node) bool {
+	threshold := time.Now().Add(-olderThan)
+	return func(node *v1.Node) bool {
+		if node.GetOnline() || !matches(node) {
+			return false
+		}
+		if activity := nodeLastActivity(node); activity.IsZero() || activity.After(threshold) {
+			return false
+		}
+		if action == staleNodeActionExpire && node.GetExpiry() != nil &&
+			!node.GetExpiry().AsTime().IsZero() && node.GetExpiry().AsTime().Before(time.Now()) {
+			return false
+		}
+		return true
+	}
+}
+
+// nodeLastActivity returns time when node was seen last time.
+// Nodes that were never seen, for example registered machines that never connected, are judged by creation time,
+// zero time is returned if both times are unknown, so such nodes are never stale.
+func nodeLastActivity(node *v1.Node) time.Time {
+	switch {
+	case node.GetLastSeen() != nil:
+		return node.GetLastSeen().AsTime()
+	case node.GetCreatedAt() != nil:
+		return node.GetCreatedAt().AsTime()
+	default:
+		return time.Time{}
+	}
+}
+
+// cleanup expires or deletes planned stale nodes. Every node is checked again before it is changed,
+// nodes that are already deleted or are not stale anymore, for example came back online after plan, are skipped.
+func (r *StaleNodeCleanupResource) cleanup(ctx context.Context, data *StaleNodeCleanupResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+	if data.Nodes.IsUnknown() {
+		nodes, nodesDiags := r.staleNodes(ctx, *data)
+		diags.Append(nodesDiags...)
+		if diags.HasError() {
+			return diags
+		}
+		data.Nodes = nodes
+	}
+	if data.DryRun.ValueBool() {
+		return diags
+	}
+
+	var nodes []StaleNodeModel
+	diags.Append(data.Nodes.ElementsAs(ctx, &nodes, false)...)
+	if diags.HasError() {
+		return diags
+	}
+	olderThan, err := time.ParseDuration(data.OlderThan.ValueString())
+	if err != nil {
+		diags.AddAttributeError(path.Root("older_than"), "Parse Duration Error", fmt.Sprintf("Unable to parse older_than, got error: %s", err))
+		return diags
+	}
+	matches, err := data.Selector.nodeMatcher()
+	if err != nil {
+		diags.AddAttributeError(path.Root("selector"), "Selector Error", err.Error())
+		return diags
+	}
+	isStale := staleNodeMatcher(matches, olderThan, data.Action.ValueString())
+
+	// snapshot can be taken before plan was approved
+	r.cache.InvalidateNodes()
+	defer r.cache.InvalidateNodes()
+	for _, node := range nodes {
+		current, err := r.cache.Node(ctx, uint64(node.Id.ValueInt64()))
+		if err != nil {
+			addClientError(&diags, fmt.Sprintf("get node %d", node.Id.ValueInt64()), err)
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if !isStale(current) {
+			diags.AddWarning(
+				"Node is not stale anymore",
+				fmt.Sprintf("Node %d %q is skipped, it does not match selector or was seen after plan.", node.Id.ValueInt64(), node.GivenName.ValueString()),
+			)
+			continue
+		}
+		switch data.Action.ValueString() {
+		case staleNodeActionDelete:
+			_, err = r.client.DeleteNode(ctx, &v1.DeleteNodeRequest{NodeId: uint64(node.Id.ValueInt64())})
+		default:
+			_, err = r.client.ExpireNode(ctx, &v1.ExpireNodeRequest{NodeId: uint64(node.Id.ValueInt64())})
+		}
+		if headscaleclient.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			addClientError(
+				&diags,
+				fmt.Sprintf("%s node %d %q", data.Action.ValueString(), node.Id.ValueInt64(), node.GivenName.ValueString()),
+				err,
+			)
+		}
+	}
+	return diags
+}
+
+func (r *StaleNodeCleanupResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_stale_node_cleanup", "create")
+	defer endOperation(&resp.Diagnostics)
+	var data StaleNodeCleanupResourceModel
+
+	// Read Terraform plan data into the model
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(r.cleanup(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	data.Id = types.StringValue(time.Now().UTC().Format(time.RFC3339))
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *StaleNodeCleanupResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_stale_node_cleanup", "read")
+	defer endOperation(&resp.Diagnostics)
+	// Stale nodes are found on plan, state keeps nodes that are affected by the last apply.
+}
+
+func (r *StaleNodeCleanupResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_stale_node_cleanup", "update")
+	defer endOperation(&resp.Diagnostics)
+	var data StaleNodeCleanupResourceModel
+
+	// Read Terraform plan data into the model
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(r.cleanup(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Save updated data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *StaleNodeCleanupResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_stale_node_cleanup", "delete")
+	defer endOperation(&resp.Diagnostics)
+	// Nodes are not restored on destroy, the resource is only removed from state.
+}
@@ -0,0 +1,135 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestStaleNodes(t *testing.T) {
+	now := time.Now()
+	old := timestamppb.New(now.Add(-48 * time.Hour))
+	recent := timestamppb.New(now.Add(-time.Hour))
+	user := &v1.User{Name: "ci"}
+	nodes := []*v1.Node{
+		{Id: 1, Name: "runner-seen-long-ago", User: user, LastSeen: old, CreatedAt: old},
+		{Id: 2, Name: "runner-seen-recently", User: user, LastSeen: recent, CreatedAt: old},
+		{Id: 3, Name: "runner-online", User: user, LastSeen: old, CreatedAt: old, Online: true},
+		{Id: 4, Name: "runner-never-seen-old", User: user, CreatedAt: old},
+		{Id: 5, Name: "runner-never-seen-new", User: user, CreatedAt: recent},
+		{Id: 6, Name: "runner-without-times", User: user},
+		{Id: 7, Name: "runner-expired", User: user, LastSeen: old, Expiry: old},
+		{Id: 8, Name: "laptop", User: &v1.User{Name: "alice"}, LastSeen: old},
+	}
+	resource := &StaleNodeCleanupResource{cache: newSnapshotCache(&fakeClient{
+		listNodes: func(ctx context.Context, _ *v1.ListNodesRequest) (*v1.ListNodesResponse, error) {
+			return &v1.ListNodesResponse{Nodes: nodes}, nil
+		},
+	})}
+
+	testCases := []struct {
+		action   string
+		expected []int64
+	}{
+		{action: staleNodeActionExpire, expected: []int64{1, 4}},
+		{action: staleNodeActionDelete, expected: []int64{1, 4, 7}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.action, func(t *testing.T) {
+			list, diags := resource.staleNodes(context.Background(), StaleNodeCleanupResourceModel{
+				Selector:  &NodeSelectorModel{User: types.StringValue("ci")},
+				OlderThan: types.StringValue("24h"),
+				Action:    types.StringValue(testCase.action),
+			})
+			if diags.HasError() {
+				t.Fatalf("unexpected errors: %v", diags)
+			}
+			var stale []StaleNodeModel
+			if diags := list.ElementsAs(context.Background(), &stale, false); diags.HasError() {
+				t.Fatalf("unexpected errors: %v", diags)
+			}
+			ids := make([]int64, 0, len(stale))
+			for _, node := range stale {
+				ids = append(ids, node.Id.ValueInt64())
+			}
+			if !slices.Equal(ids, testCase.expected) {
+				t.Errorf("expected stale nodes %v, got %v", testCase.expected, ids)
+			}
+			for _, node := range stale {
+				if node.Id.ValueInt64() == 4 && !node.LastSeen.IsNull() {
+					t.Errorf("expected null last_seen of never seen node, got %s", node.LastSeen)
+				}
+			}
+		})
+	}
+}
+
+func TestStaleNodeCleanupSkipsNodesThatAreNotStaleAnymore(t *testing.T) {
+	now := time.Now()
+	old := timestamppb.New(now.Add(-48 * time.Hour))
+	user := &v1.User{Name: "ci"}
+	planned := []*v1.Node{
+		{Id: 1, Name: "runner-stale", User: user, LastSeen: old},
+		{Id: 2, Name: "runner-online", User: user, LastSeen: old},
+		{Id: 3, Name: "runner-seen", User: user, LastSeen: old},
+		{Id: 4, Name: "runner-deleted", User: user, LastSeen: old},
+	}
+	// state of nodes at apply: node 2 came online, node 3 was seen after plan, node 4 is deleted
+	current := []*v1.Node{
+		planned[0],
+		{Id: 2, Name: "runner-online", User: user, LastSeen: old, Online: true},
+		{Id: 3, Name: "runner-seen", User: user, LastSeen: timestamppb.New(now)},
+	}
+	var deleted []uint64
+	client := &fakeClient{
+		listNodes: func(ctx context.Context, _ *v1.ListNodesRequest) (*v1.ListNodesResponse, error) {
+			return &v1.ListNodesResponse{Nodes: current}, nil
+		},
+		deleteNode: func(ctx context.Context, in *v1.DeleteNodeRequest) (*v1.DeleteNodeResponse, error) {
+			deleted = append(deleted, in.GetNodeId())
+			return &v1.DeleteNodeResponse{}, nil
+		},
+	}
+	resource := &StaleNodeCleanupResource{client: client, cache: newSnapshotCache(client)}
+
+	stale := make([]StaleNodeModel, 0, len(planned))
+	for _, node := range planned {
+		stale = append(stale, StaleNodeModel{
+			Id:        types.Int64Value(int64(node.GetId())),
+			Name:      types.StringValue(node.GetName()),
+			GivenName: types.StringValue(node.GetName()),
+			User:      types.StringValue(user.GetName()),
+			LastSeen:  types.StringValue(old.AsTime().Format(time.RFC3339)),
+		})
+	}
+	nodes, diags := types.ListValueFrom(context.Background(), types.ObjectType{AttrTypes: staleNodeAttributeTypes}, stale)
+	if diags.HasError() {
+		t.Fatalf("unexpected errors: %v", diags)
+	}
+	data := StaleNodeCleanupResourceModel{
+		Selector:  &NodeSelectorModel{User: types.StringValue("ci")},
+		OlderThan: types.StringValue("24h"),
+		Action:    types.StringValue(staleNodeActionDelete),
+		DryRun:    types.BoolValue(false),
+		Nodes:     nodes,
+	}
+
+	diags = resource.cleanup(context.Background(), &data)
+	if diags.HasError() {
+		t.Fatalf("unexpected errors: %v", diags)
+	}
+	if !slices.Equal(deleted, []uint64{1}) {
+		t.Errorf("expected only node 1 to be deleted, got %v", deleted)
+	}
+	if diags.WarningsCount() != 2 {
+		t.Errorf("expected warnings about nodes 2 and 3, got %v", diags)
+	}
+}
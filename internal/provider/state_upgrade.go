@@ -0,0 +1,43 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Schema versions of resources, resources without version are at version 0. Every change of attributes that is not compatible with existing states
+// bumps version of resource and adds state upgrader from previous version to UpgradeState of resource.
+// Prior schemas are copied as is and must never be changed, they describe states that are already stored by users.
+const (
+	userResourceVersion       = 1
+	apiKeyResourceVersion     = 1
+	preAuthKeyResourceVersion = 1
+	nodeTagsResourceVersion   = 1
+	nodeRoutesResourceVersion = 1
+)
+
+// upgradeTimestamp converts timestamp of prior state to RFC3339 in UTC, the format of current schema.
+// Value that is not a timestamp is kept as is, next read refreshes it.
+func upgradeTimestamp(value types.String) types.String {
+	if value.IsNull() || value.IsUnknown() {
+		return value
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, value.ValueString())
+	if err != nil {
+		return value
+	}
+	return types.StringValue(timestamp.UTC().Format(time.RFC3339))
+}
+
+// upgradeNodeId returns id of node based resource, id mirrors node_id.
+// Prior state without id, for example edited by hand, gets id from node_id.
+func upgradeNodeId(id types.Int64, nodeId types.Int64) types.Int64 {
+	if id.IsNull() || id.IsUnknown() {
+		return nodeId
+	}
+	return id
+}
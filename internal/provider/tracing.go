@@ -0,0 +1,253 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/paragor/terraform-provider-headscale/internal/headscaleclient"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const (
+	tracingExporterOTLP = "otlp"
+	tracingExporterFile = "file"
+
+	otlpProtocolGRPC = "grpc"
+	otlpProtocolHTTP = "http/protobuf"
+
+	tracingServiceName = "terraform-provider-headscale"
+)
+
+// TracingModel describes opentelemetry tracing of provider.
+type TracingModel struct {
+	Exporter types.String `tfsdk:"exporter"`
+	Endpoint types.String `tfsdk:"endpoint"`
+	Protocol types.String `tfsdk:"protocol"`
+	Insecure types.Bool   `tfsdk:"insecure"`
+	FilePath types.String `tfsdk:"file_path"`
+}
+
+func tracingAttribute() schema.SingleNestedAttribute {
+	return schema.SingleNestedAttribute{
+		MarkdownDescription: `
+Export opentelemetry traces of provider: span per terraform operation and per headscale call,
+trace context is propagated to headscale in grpc metadata.
+If it is not set, tracing is enabled by env "OTEL_TRACES_EXPORTER=otlp" with standard "OTEL_EXPORTER_OTLP_*" envs.
+Parent of spans is taken from env "TRACEPARENT" if it is set.
+`,
+		Optional: true,
+		Attributes: map[string]schema.Attribute{
+			"exporter": schema.StringAttribute{
+				MarkdownDescription: `
+Exporter of spans, one of:
+ - "otlp" - send spans to OTLP endpoint
+ - "file" - append spans as json lines to "file_path"
+`,
+				Required: true,
+				Validators: []validator.String{
+					stringvalidator.OneOf(tracingExporterOTLP, tracingExporterFile),
+				},
+			},
+			"endpoint": schema.StringAttribute{
+				MarkdownDescription: `OTLP endpoint, for example "otel-collector:4317" or "https://otel.example.com:4318". If it is not set, exporter takes it from env "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT" or "OTEL_EXPORTER_OTLP_ENDPOINT"`,
+				Optional:            true,
+			},
+			"protocol": schema.StringAttribute{
+				MarkdownDescription: `OTLP protocol, "grpc" or "http/protobuf". If it is not set, provider takes it from env "OTEL_EXPORTER_OTLP_TRACES_PROTOCOL" or "OTEL_EXPORTER_OTLP_PROTOCOL", default is "grpc"`,
+				Optional:            true,
+				Validators: []validator.String{
+					stringvalidator.OneOf(otlpProtocolGRPC, otlpProtocolHTTP),
+				},
+			},
+			"insecure": schema.BoolAttribute{
+				MarkdownDescription: `Send spans to OTLP endpoint without tls. If it is not set, exporter takes it from env "OTEL_EXPORTER_OTLP_INSECURE"`,
+				Optional:            true,
+			},
+			"file_path": schema.StringAttribute{
+				MarkdownDescription: `File for exporter "file", it is created with mode 0600`,
+				Optional:            true,
+			},
+		},
+	}
+}
+
+// setupTracing sets global tracer provider and propagator if tracing is configured by schema or env.
+// Tracer provider of previous Configure is shut down, so spans are flushed and its file is closed.
+func (p *HeadscaleProvider) setupTracing(ctx context.Context, tracing *TracingModel, diags *diag.Diagnostics) {
+	p.shutdownTracing(ctx)
+	if tracing == nil {
+		if strings.TrimSpace(os.Getenv("OTEL_TRACES_EXPORTER")) != tracingExporterOTLP {
+			return
+		}
+		tracing = &TracingModel{
+			Exporter: types.StringValue(tracingExporterOTLP),
+		}
+	}
+
+	exporter, file, err := p.spanExporter(ctx, tracing)
+	if err != nil {
+		diags.AddAttributeError(path.Root("tracing"), "Tracing Error", fmt.Sprintf("Unable to create span exporter, got error: %s", err))
+		return
+	}
+	res, err := sdkresource.Merge(
+		sdkresource.Default(),
+		sdkresource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(tracingServiceName),
+			semconv.ServiceVersion(p.version),
+		),
+	)
+	if err != nil {
+		tflog.Warn(ctx, "cant merge opentelemetry resource", map[string]interface{}{"error": err.Error()})
+		res = sdkresource.Default()
+	}
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override defaults of provider
+	if envResource, err := sdkresource.New(ctx, sdkresource.WithFromEnv()); err == nil {
+		if merged, err := sdkresource.Merge(res, envResource); err == nil {
+			res = merged
+		}
+	}
+
+	p.tracerProvider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	p.traceFile = file
+	otel.SetTracerProvider(p.tracerProvider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+// shutdownTracing flushes and stops tracer provider that is set by provider and closes its file,
+// global tracer provider is reset to no-op.
+func (p *HeadscaleProvider) shutdownTracing(ctx context.Context) {
+	if p.tracerProvider != nil {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		if err := p.tracerProvider.Shutdown(ctx); err != nil {
+			tflog.Warn(ctx, "cant shutdown opentelemetry tracer provider", map[string]interface{}{"error": err.Error()})
+		}
+		p.tracerProvider = nil
+	}
+	if p.traceFile != nil {
+		if err := p.traceFile.Close(); err != nil {
+			tflog.Warn(ctx, "cant close trace file", map[string]interface{}{"error": err.Error()})
+		}
+		p.traceFile = nil
+	}
+}
+
+// spanExporter returns exporter of tracing and file that exporter "file" writes to, file is nil for other exporters.
+func (p *HeadscaleProvider) spanExporter(ctx context.Context, tracing *TracingModel) (sdktrace.SpanExporter, *os.File, error) {
+	if tracing.Exporter.ValueString() == tracingExporterFile {
+		if tracing.FilePath.ValueString() == "" {
+			return nil, nil, fmt.Errorf("file_path is required for exporter %q", tracingExporterFile)
+		}
+		if err := os.MkdirAll(filepath.Dir(tracing.FilePath.ValueString()), 0o700); err != nil {
+			return nil, nil, err
+		}
+		file, err := os.OpenFile(tracing.FilePath.ValueString(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, nil, err
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			_ = file.Close()
+			return nil, nil, err
+		}
+		return exporter, file, nil
+	}
+
+	protocol := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL")
+	if protocol == "" {
+		protocol = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+	}
+	if !tracing.Protocol.IsNull() {
+		protocol = tracing.Protocol.ValueString()
+	}
+	endpoint := tracing.Endpoint.ValueString()
+	insecure := tracing.Insecure.ValueBool()
+
+	switch protocol {
+	case "", otlpProtocolGRPC:
+		var options []otlptracegrpc.Option
+		switch {
+		case strings.Contains(endpoint, "://"):
+			options = append(options, otlptracegrpc.WithEndpointURL(endpoint))
+		case endpoint != "":
+			options = append(options, otlptracegrpc.WithEndpoint(endpoint))
+		}
+		if insecure {
+			options = append(options, otlptracegrpc.WithInsecure())
+		}
+		exporter, err := otlptracegrpc.New(ctx, options...)
+		return exporter, nil, err
+	case otlpProtocolHTTP:
+		var options []otlptracehttp.Option
+		switch {
+		case strings.Contains(endpoint, "://"):
+			options = append(options, otlptracehttp.WithEndpointURL(endpoint))
+		case endpoint != "":
+			options = append(options, otlptracehttp.WithEndpoint(endpoint))
+		}
+		if insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(ctx, options...)
+		return exporter, nil, err
+	default:
+		return nil, nil, fmt.Errorf("otlp protocol must be one of %q, %q, got: %q", otlpProtocolGRPC, otlpProtocolHTTP, protocol)
+	}
+}
+
+// startOperation starts span of terraform operation, for example "create" of "headscale_user",
+// headscale calls of the operation are its child spans and are attributed to resource in audit log.
+// Returned end finishes span with status from diagnostics and flushes spans, so they are not lost when terraform stops provider.
+func startOperation(ctx context.Context, resource string, operation string) (context.Context, func(diags *diag.Diagnostics)) {
+	ctx = headscaleclient.WithOperation(ctx, headscaleclient.Operation{Resource: resource, Name: operation})
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier{
+			"traceparent": os.Getenv("TRACEPARENT"),
+			"tracestate":  os.Getenv("TRACESTATE"),
+		})
+	}
+	ctx, span := otel.Tracer(headscaleclient.TracerName).Start(
+		ctx,
+		resource+"."+operation,
+		trace.WithAttributes(
+			attribute.String("terraform.resource_type", resource),
+			attribute.String("terraform.operation", operation),
+		),
+	)
+	return ctx, func(diags *diag.Diagnostics) {
+		if diags.HasError() {
+			span.SetStatus(otelcodes.Error, fmt.Sprintf("%d errors", diags.ErrorsCount()))
+		}
+		span.End()
+		if provider, ok := otel.GetTracerProvider().(*sdktrace.TracerProvider); ok {
+			_ = provider.ForceFlush(ctx)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestSetupTracingReplacesTracerProvider(t *testing.T) {
+	t.Setenv("OTEL_TRACES_EXPORTER", "")
+	ctx := context.Background()
+	p := &HeadscaleProvider{version: "test"}
+	t.Cleanup(func() { p.shutdownTracing(ctx) })
+	dir := t.TempDir()
+	fileTracing := func(name string) *TracingModel {
+		return &TracingModel{
+			Exporter: types.StringValue(tracingExporterFile),
+			FilePath: types.StringValue(filepath.Join(dir, name)),
+		}
+	}
+	// configure sets up tracing and records span of operation
+	configure := func(tracing *TracingModel) {
+		t.Helper()
+		var diags diag.Diagnostics
+		p.setupTracing(ctx, tracing, &diags)
+		if diags.HasError() {
+			t.Fatalf("unexpected errors: %v", diags)
+		}
+		_, end := startOperation(ctx, "headscale", "configure")
+		end(&diags)
+	}
+	spans := func(name string) int {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("cant read trace file: %s", err)
+		}
+		return strings.Count(string(data), `"Name":"headscale.configure"`)
+	}
+
+	configure(fileTracing("first.jsonl"))
+	first, firstFile := p.tracerProvider, p.traceFile
+	if otel.GetTracerProvider() != first {
+		t.Fatalf("expected global tracer provider to be set")
+	}
+
+	configure(fileTracing("second.jsonl"))
+	if p.tracerProvider == first || otel.GetTracerProvider() != p.tracerProvider {
+		t.Errorf("expected tracer provider to be replaced")
+	}
+	if _, err := firstFile.WriteString("{}\n"); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("expected previous trace file to be closed, got error %v", err)
+	}
+	if isRecording(first) {
+		t.Errorf("expected previous tracer provider to be shut down")
+	}
+	if spans("first.jsonl") != 1 || spans("second.jsonl") != 1 {
+		t.Errorf("expected one span in every trace file, got %d and %d", spans("first.jsonl"), spans("second.jsonl"))
+	}
+
+	second := p.tracerProvider
+	configure(nil)
+	if p.tracerProvider != nil || p.traceFile != nil {
+		t.Errorf("expected tracing to be disabled")
+	}
+	if _, ok := otel.GetTracerProvider().(*sdktrace.TracerProvider); ok {
+		t.Errorf("expected global tracer provider to be no-op")
+	}
+	if isRecording(second) {
+		t.Errorf("expected tracer provider to be shut down")
+	}
+	if spans("second.jsonl") != 1 {
+		t.Errorf("expected no spans after tracing is disabled, got %d", spans("second.jsonl"))
+	}
+}
+
+// isRecording reports whether tracer provider records spans, it does not after shutdown.
+func isRecording(provider *sdktrace.TracerProvider) bool {
+	_, span := provider.Tracer("test").Start(context.Background(), "test")
+	defer span.End()
+	return span.IsRecording()
+}
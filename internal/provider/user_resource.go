@@ -16,11 +16,13 @@ import (
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/paragor/terraform-provider-headscale/internal/headscaleclient"
 )
 
 // Ensure provider defined types fully satisfy framework interfaces.
 var _ resource.Resource = &UserResource{}
 var _ resource.ResourceWithImportState = &UserResource{}
+var _ resource.ResourceWithUpgradeState = &UserResource{}
 
 func NewUserResource() resource.Resource {
 	return &UserResource{}
@@ -28,7 +30,8 @@ func NewUserResource() resource.Resource {
 
 // UserResource defines the resource implementation.
 type UserResource struct {
-	client v1.HeadscaleServiceClient
+	client headscaleclient.Client
+	cache  *snapshotCache
 }
 
 type UserResourceModel struct {
@@ -45,6 +48,8 @@ func (r *UserResource) Metadata(ctx context.Context, req resource.MetadataReques
 
 func (r *UserResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
+		Version: userResourceVersion,
+
 		// This description is used by the documentation generator and the language server.
 		MarkdownDescription: "The user resource that create user",
 
@@ -105,6 +110,7 @@ func (r *UserResource) Configure(ctx context.Context, req resource.ConfigureRequ
 	}
 
 	r.client = config.client
+	r.cache = config.cache
 }
 
 func (r *UserResource) readComputedFields(user *v1.User, data *UserResourceModel) {
@@ -124,6 +130,8 @@ func (r *UserResource) readComputedFields(user *v1.User, data *UserResourceModel
 }
 
 func (r *UserResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_user", "create")
+	defer endOperation(&resp.Diagnostics)
 	var data UserResourceModel
 
 	// Read Terraform plan data into the model
@@ -143,8 +151,9 @@ func (r *UserResource) Create(ctx context.Context, req resource.CreateRequest, r
 		createUserRequest.Email = data.Email.ValueString()
 	}
 	response, err := r.client.CreateUser(ctx, createUserRequest)
+	r.cache.InvalidateUsers()
 	if err != nil {
-		resp.Diagnostics.AddError("Client Error", fmt.Sprintf("Unable to create user, got error: %s", err))
+		addClientAttributeError(&resp.Diagnostics, path.Root("name"), "create user", err)
 		return
 	}
 	r.readComputedFields(response.User, &data)
@@ -153,6 +162,8 @@ func (r *UserResource) Create(ctx context.Context, req resource.CreateRequest, r
 }
 
 func (r *UserResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_user", "read")
+	defer endOperation(&resp.Diagnostics)
 	var data UserResourceModel
 
 	// Read Terraform prior state data into the model
@@ -162,23 +173,24 @@ func (r *UserResource) Read(ctx context.Context, req resource.ReadRequest, resp
 		return
 	}
 
-	response, err := r.client.ListUsers(ctx, &v1.ListUsersRequest{Id: uint64(data.Id.ValueInt64())})
+	user, err := r.cache.User(ctx, uint64(data.Id.ValueInt64()))
 	if err != nil {
-		resp.Diagnostics.AddError("Client Error", fmt.Sprintf("Unable to list users, got error: %s", err))
+		addClientError(&resp.Diagnostics, "list users", err)
 		return
 	}
-	if response.GetUsers() == nil || len(response.Users) != 1 {
-		resp.Diagnostics.AddError("user not found", "user not found")
-		resp.State.RemoveResource(ctx)
+	if user == nil {
+		removeNotFoundResource(ctx, &resp.State, &resp.Diagnostics, fmt.Sprintf("user %d", data.Id.ValueInt64()))
 		return
 	}
 
-	r.readComputedFields(response.GetUsers()[0], &data)
+	r.readComputedFields(user, &data)
 	// Save updated data into Terraform state
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
 func (r *UserResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_user", "update")
+	defer endOperation(&resp.Diagnostics)
 	var data UserResourceModel
 
 	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
@@ -192,8 +204,9 @@ func (r *UserResource) Update(ctx context.Context, req resource.UpdateRequest, r
 		OldId:   uint64(data.Id.ValueInt64()),
 		NewName: newName,
 	})
+	r.cache.InvalidateUsers()
 	if err != nil {
-		resp.Diagnostics.AddError("Client Error", fmt.Sprintf("Unable to rename user, got error: %s", err))
+		addClientAttributeError(&resp.Diagnostics, path.Root("name"), "rename user", err)
 		return
 	}
 	r.readComputedFields(response.GetUser(), &data)
@@ -202,6 +215,8 @@ func (r *UserResource) Update(ctx context.Context, req resource.UpdateRequest, r
 }
 
 func (r *UserResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, endOperation := startOperation(ctx, "headscale_user", "delete")
+	defer endOperation(&resp.Diagnostics)
 	var data UserResourceModel
 
 	// Read Terraform prior state data into the model
@@ -214,12 +229,46 @@ func (r *UserResource) Delete(ctx context.Context, req resource.DeleteRequest, r
 	_, err := r.client.DeleteUser(ctx, &v1.DeleteUserRequest{
 		Id: uint64(data.Id.ValueInt64()),
 	})
-	if err != nil {
-		resp.Diagnostics.AddError("Client Error", fmt.Sprintf("Unable to delete user, got error: %s", err))
+	r.cache.InvalidateUsers()
+	if err != nil && !headscaleclient.IsNotFound(err) {
+		addClientError(&resp.Diagnostics, "delete user", err)
 		return
 	}
 }
 
 func (r *UserResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
-	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
+	user, err := findUserByReference(ctx, r.client, req.ID)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Fail to find user",
+			fmt.Sprintf("Expected import identifier is user id, name or email: %s", err),
+		)
+		return
+	}
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), types.Int64Value(int64(user.GetId())))...)
+}
+
+func (r *UserResource) UpgradeState(ctx context.Context) map[int64]resource.StateUpgrader {
+	return map[int64]resource.StateUpgrader{
+		0: {
+			PriorSchema: &schema.Schema{
+				Attributes: map[string]schema.Attribute{
+					"id":           schema.Int64Attribute{Computed: true},
+					"name":         schema.StringAttribute{Required: true},
+					"display_name": schema.StringAttribute{Optional: true},
+					"email":        schema.StringAttribute{Optional: true},
+					"created_at":   schema.StringAttribute{Computed: true},
+				},
+			},
+			StateUpgrader: func(ctx context.Context, req resource.UpgradeStateRequest, resp *resource.UpgradeStateResponse) {
+				var data UserResourceModel
+				resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+				if resp.Diagnostics.HasError() {
+					return
+				}
+				data.CreatedAt = upgradeTimestamp(data.CreatedAt)
+				resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+			},
+		},
+	}
 }
@@ -0,0 +1,51 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	v1 "github.com/juanfont/headscale/gen/go/headscale/v1"
+	"github.com/paragor/terraform-provider-headscale/internal/headscaleclient"
+)
+
+// findUserByName returns user by name via ListUsers name filter.
+func findUserByName(ctx context.Context, client headscaleclient.Client, name string) (*v1.User, error) {
+	return findUser(ctx, client, &v1.ListUsersRequest{Name: name}, fmt.Sprintf("name %q", name))
+}
+
+// findUserByReference returns user by id, name or email, for example from import identifier.
+func findUserByReference(ctx context.Context, client headscaleclient.Client, reference string) (*v1.User, error) {
+	if id, err := strconv.ParseUint(reference, 10, 64); err == nil {
+		return findUser(ctx, client, &v1.ListUsersRequest{Id: id}, fmt.Sprintf("id %d", id))
+	}
+	user, err := findUserByName(ctx, client, reference)
+	if err == nil || !strings.Contains(reference, "@") {
+		return user, err
+	}
+	return findUser(ctx, client, &v1.ListUsersRequest{Email: reference}, fmt.Sprintf("email %q", reference))
+}
+
+func findUser(
+	ctx context.Context,
+	client headscaleclient.Client,
+	request *v1.ListUsersRequest,
+	description string,
+) (*v1.User, error) {
+	response, err := client.ListUsers(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list users with %s: %w", description, err)
+	}
+	switch len(response.GetUsers()) {
+	case 0:
+		return nil, fmt.Errorf("user with %s is not found", description)
+	case 1:
+		return response.GetUsers()[0], nil
+	default:
+		return nil, fmt.Errorf("found %d users with %s, use user id instead", len(response.GetUsers()), description)
+	}
+}
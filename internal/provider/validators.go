@@ -0,0 +1,232 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+)
+
+var (
+	ttlRegexp = regexp.MustCompile(`^\d+(ns|us|µs|ms|s|m|h)$`)
+	tagRegexp = regexp.MustCompile(`^tag:[\w-]+$`)
+	// givenNameRegexp follows headscale rules for dns labels of node names.
+	givenNameRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+	// legacyPreAuthKeyRegexp matches keys of headscale v0.26 and older: 24 random bytes in hex.
+	legacyPreAuthKeyRegexp = regexp.MustCompile(`^[0-9a-f]{48}$`)
+	// prefixedPreAuthKeyRegexp matches keys of newer headscale: hskey-auth-<prefix>-<secret>.
+	prefixedPreAuthKeyRegexp = regexp.MustCompile(`^hskey-auth-([A-Za-z0-9_]+)-([A-Za-z0-9_-]+)$`)
+)
+
+const (
+	preAuthKeyFormatLegacy   = "legacy"
+	preAuthKeyFormatPrefixed = "prefixed"
+)
+
+// maxGivenNameLength is max length of dns label.
+const maxGivenNameLength = 63
+
+// maxFQDNLength is max length of node fqdn in headscale.
+const maxFQDNLength = 255
+
+// stringCheckValidator validates string value with check function.
+// It is used in resource schemas and in parameters of provider functions,
+// so both report the same errors for the same values.
+type stringCheckValidator struct {
+	description string
+	check       func(value string) error
+}
+
+var _ validator.String = stringCheckValidator{}
+var _ function.StringParameterValidator = stringCheckValidator{}
+
+func (v stringCheckValidator) Description(ctx context.Context) string {
+	return v.description
+}
+
+func (v stringCheckValidator) MarkdownDescription(ctx context.Context) string {
+	return v.description
+}
+
+func (v stringCheckValidator) ValidateString(ctx context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	if err := v.check(req.ConfigValue.ValueString()); err != nil {
+		resp.Diagnostics.AddAttributeError(
+			req.Path,
+			"Invalid Attribute Value",
+			fmt.Sprintf("Attribute %s %s, got: %s", req.Path, err, req.ConfigValue.ValueString()),
+		)
+	}
+}
+
+func (v stringCheckValidator) ValidateParameterString(
+	ctx context.Context,
+	req function.StringParameterValidatorRequest,
+	resp *function.StringParameterValidatorResponse,
+) {
+	if req.Value.IsNull() || req.Value.IsUnknown() {
+		return
+	}
+	if err := v.check(req.Value.ValueString()); err != nil {
+		resp.Error = function.NewArgumentFuncError(req.ArgumentPosition, err.Error())
+	}
+}
+
+func ttlValidator() stringCheckValidator {
+	return stringCheckValidator{
+		description: `value must be duration. Valid time units are "ns", "us" (or "µs"), "ms", "s", "m", "h"`,
+		check:       checkTTL,
+	}
+}
+
+func tagValidator() stringCheckValidator {
+	return stringCheckValidator{
+		description: "value must follow scheme of `tag:<value>`",
+		check:       checkTag,
+	}
+}
+
+func routeValidator() stringCheckValidator {
+	return stringCheckValidator{
+		description: "value must be route prefix, for example `10.0.0.0/8`",
+		check:       checkRoute,
+	}
+}
+
+func givenNameValidator() stringCheckValidator {
+	return stringCheckValidator{
+		description: "value must be valid dns label",
+		check:       checkGivenName,
+	}
+}
+
+func preAuthKeyValidator() stringCheckValidator {
+	return stringCheckValidator{
+		description: "value must be headscale pre auth key",
+		check: func(value string) error {
+			_, err := parsePreAuthKey(value)
+			return err
+		},
+	}
+}
+
+func regexpValidator() stringCheckValidator {
+	return stringCheckValidator{
+		description: "value must be valid regular expression",
+		check: func(value string) error {
+			_, err := regexp.Compile(value)
+			return err
+		},
+	}
+}
+
+func globValidator() stringCheckValidator {
+	return stringCheckValidator{
+		description: "value must be valid glob pattern",
+		check: func(value string) error {
+			_, err := path.Match(value, "")
+			return err
+		},
+	}
+}
+
+func policyValidator() stringCheckValidator {
+	return stringCheckValidator{
+		description: "value must be valid headscale policy in HuJSON",
+		check: func(value string) error {
+			_, err := parsePolicy(value)
+			return err
+		},
+	}
+}
+
+// confirmedValidator requires explicit true for destructive operations.
+type confirmedValidator struct{}
+
+var _ validator.Bool = confirmedValidator{}
+
+func (v confirmedValidator) Description(ctx context.Context) string {
+	return "value must be true to confirm destructive operation"
+}
+
+func (v confirmedValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v confirmedValidator) ValidateBool(ctx context.Context, req validator.BoolRequest, resp *validator.BoolResponse) {
+	if req.ConfigValue.IsUnknown() || req.ConfigValue.ValueBool() {
+		return
+	}
+	resp.Diagnostics.AddAttributeError(
+		req.Path,
+		"Operation is not confirmed",
+		fmt.Sprintf("Attribute %s %s", req.Path, v.Description(ctx)),
+	)
+}
+
+func checkTTL(ttl string) error {
+	if !ttlRegexp.MatchString(ttl) {
+		return errors.New(`must be duration. Valid time units are "ns", "us" (or "µs"), "ms", "s", "m", "h"`)
+	}
+	return nil
+}
+
+func checkTag(tag string) error {
+	if !tagRegexp.MatchString(tag) {
+		return errors.New("tag must follow scheme of `tag:<value>`")
+	}
+	return nil
+}
+
+func checkRoute(route string) error {
+	_, err := parseRoute(route)
+	return err
+}
+
+// parseRoute parses route prefix, for example "10.0.0.0/8" or "fd7a:115c:a1e0::/48".
+func parseRoute(route string) (netip.Prefix, error) {
+	prefix, err := netip.ParsePrefix(route)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("route must follow scheme of `net/mask`: %w", err)
+	}
+	return prefix, nil
+}
+
+func checkGivenName(name string) error {
+	if len(name) < 2 || len(name) > maxGivenNameLength {
+		return fmt.Errorf("name must be from 2 to %d characters long", maxGivenNameLength)
+	}
+	if !givenNameRegexp.MatchString(name) {
+		return errors.New("name must contain only lowercase ASCII letters, numbers and hyphens")
+	}
+	return nil
+}
+
+type parsedPreAuthKey struct {
+	format string
+	prefix string
+}
+
+func parsePreAuthKey(key string) (parsedPreAuthKey, error) {
+	if legacyPreAuthKeyRegexp.MatchString(key) {
+		return parsedPreAuthKey{format: preAuthKeyFormatLegacy}, nil
+	}
+	if match := prefixedPreAuthKeyRegexp.FindStringSubmatch(key); match != nil {
+		return parsedPreAuthKey{format: preAuthKeyFormatPrefixed, prefix: match[1]}, nil
+	}
+	if strings.TrimSpace(key) != key {
+		return parsedPreAuthKey{}, errors.New("pre auth key must not contain leading or trailing spaces")
+	}
+	return parsedPreAuthKey{}, errors.New("pre auth key must be 48 hex characters or follow scheme of `hskey-auth-<prefix>-<secret>`")
+}